@@ -16,9 +16,11 @@ require (
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/hashicorp/go-version v1.2.0
 	github.com/hashicorp/hcl v0.0.0-20170504190234-a4b07c25de5f
+	github.com/hashicorp/hcl/v2 v2.0.0
 	github.com/hashicorp/nomad/api v0.0.0-20200812181322-71e8a68d9948
 	github.com/hashicorp/terraform-plugin-sdk v1.15.0
 	github.com/hashicorp/vault v0.10.4
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/stretchr/testify v1.5.1
+	github.com/zclconf/go-cty v1.2.1
 )
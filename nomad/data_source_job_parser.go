@@ -2,11 +2,20 @@ package nomad
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"strings"
 
+	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+const (
+	JobParserModeLocal  = "local"
+	JobParserModeRemote = "remote"
 )
 
 func dataSourceJobParser() *schema.Resource {
@@ -24,6 +33,16 @@ func dataSourceJobParser() *schema.Resource {
 				Optional:    true,
 				Default:     false,
 			},
+			"mode": {
+				Description: "Where to parse the job: `local` parses it in the provider, `remote` uses the Nomad API.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     JobParserModeRemote,
+				ValidateFunc: validation.StringInSlice([]string{
+					JobParserModeLocal,
+					JobParserModeRemote,
+				}, false),
+			},
 			"json": {
 				Description: "The parsed job as JSON string.",
 				Type:        schema.TypeString,
@@ -34,21 +53,38 @@ func dataSourceJobParser() *schema.Resource {
 }
 
 func dataSourceJobParserRead(d *schema.ResourceData, meta interface{}) error {
-	providerConfig := meta.(ProviderConfig)
-	client := providerConfig.client
-
 	hcl := d.Get("hcl").(string)
 	canonicalize := d.Get("canonicalize").(bool)
+	mode := d.Get("mode").(string)
 
-	log.Printf("[DEBUG] Parsing Job with Canonicalize set to %t", canonicalize)
-	job, err := client.Jobs().ParseHCL(hcl, canonicalize)
+	if mode == JobParserModeRemote && addressUnknown(meta) {
+		log.Printf("[WARN] address of the provider unknown, not parsing the job with Nomad")
+		return nil
+	}
+
+	log.Printf("[DEBUG] Parsing Job with Canonicalize set to %t in %s mode", canonicalize, mode)
+
+	var job *api.Job
+	var err error
+	if mode == JobParserModeLocal {
+		job, err = parseJobHCLLocal(hcl, canonicalize)
+	} else {
+		providerConfig := meta.(ProviderConfig)
+		client := providerConfig.client
+
+		job, err = client.Jobs().ParseHCL(hcl, canonicalize)
+		if err != nil && jobParserCanFallBack(err) {
+			log.Printf("[WARN] failed to parse job with the Nomad API, parsing it locally: %s", err)
+			job, err = parseJobHCLLocal(hcl, canonicalize)
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("error parsing job: %#v", err)
+		return fmt.Errorf("error parsing job: %s", err)
 	}
 
 	jobJSON, err := json.Marshal(job)
 	if err != nil {
-		return fmt.Errorf("error parsing job: %#v", err)
+		return fmt.Errorf("error parsing job: %s", err)
 	}
 
 	jobJSONString := string(jobJSON)
@@ -56,7 +92,34 @@ func dataSourceJobParserRead(d *schema.ResourceData, meta interface{}) error {
 	d.SetId(*job.ID)
 	d.Set("hcl", strings.TrimSpace(hcl))
 	d.Set("canonicalize", canonicalize)
+	d.Set("mode", mode)
 	d.Set("json", strings.TrimSpace(jobJSONString))
 
 	return nil
 }
+
+// parseJobHCLLocal parses the jobspec with the same parser used by the
+// /v1/jobs/parse endpoint of the Nomad API, without contacting the server.
+func parseJobHCLLocal(hcl string, canonicalize bool) (*api.Job, error) {
+	job, err := parseHCLJobspec(hcl)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.ID == nil {
+		return nil, fmt.Errorf("jobspec is not a valid Nomad job")
+	}
+
+	if canonicalize {
+		job.Canonicalize()
+	}
+
+	return job, nil
+}
+
+// jobParserCanFallBack returns whether a job that the Nomad API failed to
+// parse with err can be parsed locally instead: when Nomad cannot be reached
+// or when the token is not allowed to parse jobs.
+func jobParserCanFallBack(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || strings.Contains(err.Error(), "403")
+}
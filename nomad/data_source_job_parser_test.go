@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 )
 
@@ -33,6 +36,105 @@ func TestAccDataSourceNomadJobParser_Basic(t *testing.T) {
 	})
 }
 
+func TestAccDataSourceNomadJobParser_LocalMode(t *testing.T) {
+	resourceName := "data.nomad_job_parser.test_job"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testJobParserLocalModeConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						resourceName, "mode", "local"),
+					resource.TestCheckResourceAttr(
+						resourceName, "json", testDataSourceJobParserJSON(t)),
+				),
+			},
+			{
+				Config:      testDataSourceJobParserInvalidHCLLocalModeConfig,
+				ExpectError: regexp.MustCompile("error parsing job"),
+			},
+		},
+	})
+}
+
+func TestParseJobHCLLocal(t *testing.T) {
+	job, err := parseJobHCLLocal(testDataSourceJobParserHCL, false)
+	if err != nil {
+		t.Fatalf("error parsing job: %s", err)
+	}
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		t.Fatalf("error encoding job: %s", err)
+	}
+	if expected := testDataSourceJobParserJSON(t); string(jobJSON) != expected {
+		t.Fatalf("expected %s, got %s", expected, jobJSON)
+	}
+
+	job, err = parseJobHCLLocal(testDataSourceJobParserHCL, true)
+	if err != nil {
+		t.Fatalf("error parsing job: %s", err)
+	}
+	if job.Type == nil || *job.Type != "service" {
+		t.Fatalf("expected job to be canonicalized, got type %v", job.Type)
+	}
+
+	if _, err := parseJobHCLLocal("", false); err == nil {
+		t.Fatal("expected error parsing empty jobspec")
+	}
+}
+
+func TestDataSourceJobParserRead_fallback(t *testing.T) {
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	for name, address := range map[string]string{
+		"forbidden":   forbidden.URL,
+		"unreachable": unreachable.URL,
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, err := api.NewClient(&api.Config{Address: address})
+			if err != nil {
+				t.Fatalf("error creating client: %s", err)
+			}
+
+			d := dataSourceJobParser().TestResourceData()
+			d.Set("hcl", testDataSourceJobParserHCL)
+			d.Set("mode", JobParserModeRemote)
+			if err := dataSourceJobParserRead(d, ProviderConfig{client: client}); err != nil {
+				t.Fatalf("error reading data source: %s", err)
+			}
+			if expected := testDataSourceJobParserJSON(t); d.Get("json").(string) != expected {
+				t.Fatalf("expected %s, got %s", expected, d.Get("json"))
+			}
+		})
+	}
+
+	// Other errors are returned as is
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid job", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	d := dataSourceJobParser().TestResourceData()
+	d.Set("hcl", testDataSourceJobParserHCL)
+	d.Set("mode", JobParserModeRemote)
+	if err := dataSourceJobParserRead(d, ProviderConfig{client: client}); err == nil || !strings.Contains(err.Error(), "invalid job") {
+		t.Fatalf("expected the error of the Nomad API, got %v", err)
+	}
+}
+
 func TestAccDataSourceNomadJobParser_InvalidHCL(t *testing.T) {
 	re := regexp.MustCompile("error parsing job")
 
@@ -88,6 +190,16 @@ EOT
 
 }
 
+func testJobParserLocalModeConfig() string {
+	return fmt.Sprintf(`
+data "nomad_job_parser" "test_job" {
+  mode = "local"
+  hcl  = <<EOT
+%s
+EOT
+}`, testDataSourceJobParserHCL)
+}
+
 const testDataSourceJobParserHCL = `
 job "example" {
   datacenters = ["dc1"]
@@ -252,6 +364,12 @@ data "nomad_job_parser" "test_job" {
 	hcl = "invalid"
 }`
 
+const testDataSourceJobParserInvalidHCLLocalModeConfig = `
+data "nomad_job_parser" "test_job" {
+	mode = "local"
+	hcl  = "invalid"
+}`
+
 const testDataSourceJobParserEmptyHCLConfig = `
 data "nomad_job_parser" "test_job" {
 	hcl = ""
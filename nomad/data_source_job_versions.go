@@ -0,0 +1,119 @@
+package nomad
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceJobVersions() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceJobVersionsRead,
+		Schema: map[string]*schema.Schema{
+
+			"job_id": {
+				Description: "Job ID",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"namespace": {
+				Description: "Job Namespace",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "default",
+			},
+			// computed attributes
+			"versions": {
+				Description: "Job Versions, from the most recent to the oldest",
+				Computed:    true,
+				Type:        schema.TypeList,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"version": {
+							Description: "Job Version",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"submit_time": {
+							Description: "Job Submit Time",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"stable": {
+							Description: "Job Stability",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						"stop": {
+							Description: "Job Stop",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						"diff": {
+							Description: "Diff against the previous version",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceJobVersionsRead(d *schema.ResourceData, meta interface{}) error {
+	providerConfig := meta.(ProviderConfig)
+	client := providerConfig.client
+
+	id := d.Get("job_id").(string)
+	ns := d.Get("namespace").(string)
+	if ns == "" {
+		ns = "default"
+	}
+	log.Printf("[DEBUG] Getting job versions: %q/%q", ns, id)
+	versions, diffs, _, err := client.Jobs().Versions(id, true, &api.QueryOptions{
+		Namespace: ns,
+	})
+	if err != nil {
+		return fmt.Errorf("error reading job versions: %s", err)
+	}
+
+	d.SetId(id)
+	d.Set("namespace", ns)
+	if err := d.Set("versions", flattenJobVersions(versions, diffs)); err != nil {
+		return fmt.Errorf("error setting job versions: %s", err)
+	}
+
+	return nil
+}
+
+// flattenJobVersions returns the versions of a job as returned by the Nomad
+// API, from the most recent to the oldest. diffs[i] is the diff between
+// versions[i+1] and versions[i], so the oldest version has no diff.
+func flattenJobVersions(versions []*api.Job, diffs []*api.JobDiff) []interface{} {
+	result := make([]interface{}, 0, len(versions))
+	for i, v := range versions {
+		entry := map[string]interface{}{
+			"diff": "",
+		}
+		if v.Version != nil {
+			entry["version"] = int(*v.Version)
+		}
+		if v.SubmitTime != nil {
+			entry["submit_time"] = int(*v.SubmitTime)
+		}
+		if v.Stable != nil {
+			entry["stable"] = *v.Stable
+		}
+		if v.Stop != nil {
+			entry["stop"] = *v.Stop
+		}
+		if i < len(diffs) {
+			entry["diff"] = formatJobDiff(diffs[i])
+		}
+		result = append(result, entry)
+	}
+	return result
+}
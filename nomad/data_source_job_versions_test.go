@@ -0,0 +1,132 @@
+package nomad
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccDataSourceNomadJobVersions_Basic(t *testing.T) {
+	job := "testjobversionsds"
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testProviders,
+		CheckDestroy: testResourceJob_forceDestroyWithPurge(job, "default"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccJobVersionsDataSourceConfig(job, "1"),
+			},
+			{
+				Config: testAccJobVersionsDataSourceConfig(job, "2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.nomad_job_versions.test", "versions.#", "2"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job_versions.test", "versions.0.version", "1"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job_versions.test", "versions.1.version", "0"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job_versions.test", "versions.1.diff", ""),
+					testAccDataSourceNomadJobVersionsDiff("data.nomad_job_versions.test",
+						`+/- version: "1" => "2"`),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceNomadJobVersionsDiff(name, want string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("%s not found in state", name)
+		}
+
+		diff := rs.Primary.Attributes["versions.0.diff"]
+		if !strings.Contains(diff, want) {
+			return fmt.Errorf("expected diff to contain %q, got:\n%s", want, diff)
+		}
+		return nil
+	}
+}
+
+func TestFlattenJobVersions(t *testing.T) {
+	version := func(v uint64, stable bool) *api.Job {
+		submitTime := int64(1000 + v)
+		stop := false
+		return &api.Job{
+			Version:    &v,
+			SubmitTime: &submitTime,
+			Stable:     &stable,
+			Stop:       &stop,
+		}
+	}
+
+	versions := []*api.Job{version(1, false), version(0, true)}
+	diffs := []*api.JobDiff{
+		{
+			Type: "Edited",
+			ID:   "foo",
+			Fields: []*api.FieldDiff{
+				{Type: "Edited", Name: "Priority", Old: "50", New: "60"},
+			},
+		},
+	}
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"version":     1,
+			"submit_time": 1001,
+			"stable":      false,
+			"stop":        false,
+			"diff":        "+/- Job: \"foo\"\n  +/- Priority: \"50\" => \"60\"",
+		},
+		map[string]interface{}{
+			"version":     0,
+			"submit_time": 1000,
+			"stable":      true,
+			"stop":        false,
+			"diff":        "",
+		},
+	}, flattenJobVersions(versions, diffs))
+}
+
+func testAccJobVersionsDataSourceConfig(job, version string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "test" {
+	jobspec = <<EOT
+job "%s" {
+	datacenters = ["dc1"]
+	type = "batch"
+	meta {
+		version = "%s"
+	}
+	group "foo" {
+		task "foo" {
+			driver = "raw_exec"
+			config {
+				command = "/bin/sleep"
+				args    = ["1"]
+			}
+			resources {
+				cpu = 100
+				memory = 10
+			}
+		}
+	}
+}
+EOT
+}
+
+data "nomad_job_versions" "test" {
+	job_id = nomad_job.test.id
+
+	depends_on = [nomad_job.test]
+}
+`, job, version)
+}
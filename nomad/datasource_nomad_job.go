@@ -97,187 +97,7 @@ func dataSourceJob() *schema.Resource {
 				Computed:    true,
 				Type:        schema.TypeString,
 			},
-			"task_groups": {
-				Description: "Job Task Groups",
-				Computed:    true,
-				Type:        schema.TypeList,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"name": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-						"count": {
-							Type:     schema.TypeInt,
-							Computed: true,
-						},
-						"constraints": {
-							Type:     schema.TypeMap,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"ltarget": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"rtarget": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"operand": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-								},
-							},
-						},
-						//"tasks": {
-						//	Type:     schema.TypeInt,
-						//	Computed: true,
-						//	Type:        schema.TypeList,
-						//	Elem: &schema.Resource{
-						//		Schema: map[string]*schema.Schema{
-						//		}
-						//},
-						"restart_policy": {
-							Type:     schema.TypeMap,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"interval": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"attempts": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"delay": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"mode": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-								},
-							},
-						},
-						"reschedule_policy": {
-							Type:     schema.TypeMap,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"attempts": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"interval": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"delay": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"delay_function": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"max_delay": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"unlimited": {
-										Type:     schema.TypeBool,
-										Computed: true,
-									},
-								},
-							},
-						},
-						"ephemeral_disk": {
-							Type:     schema.TypeMap,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"sticky": {
-										Type:     schema.TypeBool,
-										Computed: true,
-									},
-									"migrate": {
-										Type:     schema.TypeBool,
-										Computed: true,
-									},
-									"size_mb": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-								},
-							},
-						},
-						"update_strategy": {
-							Computed: true,
-							Type:     schema.TypeMap,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"stagger": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"max_parallel": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"health_check": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"min_healthy_time": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"healthy_deadline": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"auto_revert": {
-										Type:     schema.TypeBool,
-										Computed: true,
-									},
-									"canary": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-								},
-							},
-						},
-						"migrate_strategy": {
-							Type:     schema.TypeMap,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"max_parallel": {
-										Type:     schema.TypeInt,
-										Computed: true,
-									},
-									"health_check": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"min_healthy_time": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"healthy_deadline": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+			"task_groups": jobTaskGroupsSchema(),
 			"stable": {
 				Description: "Job Stable",
 				Type:        schema.TypeBool,
@@ -418,7 +238,7 @@ func dataSourceJobRead(d *schema.ResourceData, meta interface{}) error {
 	d.Set("stop", job.Stop)
 	d.Set("priority", job.Priority)
 	d.Set("parent_id", job.ParentID)
-	d.Set("task_groups", job.TaskGroups)
+	d.Set("task_groups", jobTaskGroupsRaw(job.TaskGroups))
 	d.Set("stable", job.Stable)
 	d.Set("all_at_once", job.AllAtOnce)
 	d.Set("contraints", job.Constraints)
@@ -29,6 +29,20 @@ func TestAccDataSourceNomadJob_Basic(t *testing.T) {
 						"data.nomad_job.test-job", "priority", "50"),
 					resource.TestCheckResourceAttr(
 						"data.nomad_job.test-job", "namespace", "default"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.name", "foo"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.update_strategy.0.max_parallel", "2"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.update_strategy.0.min_healthy_time", "11s"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.task.0.config.command", "/bin/echo"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.task.0.config.args", `["test"]`),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.task.0.resources.0.cpu", "100"),
+					resource.TestCheckResourceAttr(
+						"data.nomad_job.test-job", "task_groups.0.task.0.resources.0.memory", "10"),
 				),
 			},
 		},
@@ -1,27 +1,58 @@
 package nomad
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 	"github.com/hashicorp/vault/command/config"
 )
 
 type ProviderConfig struct {
-	client     *api.Client
-	vaultToken *string
+	client      *api.Client
+	vaultToken  *tokenSource
+	consulToken *string
+
+	// retry is the configuration of the retries of the requests made by
+	// client.
+	retry retryConfig
+
+	// addressUnknown is set when the address of the provider is not known
+	// yet, for example during the plan of the cluster it targets. The
+	// requests made by client fail with errAddressUnknown.
+	addressUnknown bool
 }
 
+// errAddressUnknown is the error returned by the requests made while the
+// address of the provider is unknown.
+var errAddressUnknown = errors.New("the address of the Nomad provider is not known yet, it will be known after apply")
+
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	provider := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"address": {
 				Type:        schema.TypeString,
 				Required:    true,
 				DefaultFunc: schema.EnvDefaultFunc("NOMAD_ADDR", nil),
 				Description: "URL of the root of the target Nomad agent.",
+				// An empty address is replaced by the default address so
+				// that it can be told apart from an unknown address.
+				StateFunc: func(v interface{}) string {
+					if v.(string) == "" {
+						return api.DefaultConfig().Address
+					}
+					return v.(string)
+				},
 			},
 
 			"region": {
@@ -48,47 +79,233 @@ func Provider() terraform.ResourceProvider {
 				DefaultFunc: schema.EnvDefaultFunc("NOMAD_CLIENT_KEY", ""),
 				Description: "A path to a PEM-encoded private key, required if cert_file is specified.",
 			},
+			"ca_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"ca_file"},
+				Description:   "PEM-encoded certificate authority used to verify the remote agent's certificate.",
+			},
+			"cert_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"cert_file"},
+				Description:   "PEM-encoded certificate provided to the remote agent; requires use of key_file or key_pem.",
+			},
+			"key_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"key_file"},
+				Description:   "PEM-encoded private key, required if cert_file or cert_pem is specified.",
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("NOMAD_TLS_SERVER_NAME", ""),
+				Description: "Server name used to verify the remote agent's certificate and as SNI host.",
+			},
+			"skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("NOMAD_SKIP_VERIFY", false),
+				Description: "Skip the verification of the remote agent's certificate.",
+			},
 			"vault_token": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("VAULT_TOKEN", ""),
 				Description: "Vault token if policies are specified in the job file.",
 			},
+			"vault_token_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"vault_token"},
+				Description:   "Path to a file containing the Vault token if policies are specified in the job file.",
+			},
+			"consul_token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CONSUL_HTTP_TOKEN", ""),
+				Description: "Consul token to validate Consul Connect Service Identity policies specified in the job file.",
+			},
 			"secret_id": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("NOMAD_TOKEN", ""),
 				Description: "ACL token secret for API requests.",
 			},
+			"secret_id_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"secret_id"},
+				Description:   "Path to a file containing the ACL token secret for API requests.",
+			},
+			"token_command": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    2,
+				Description: "Command run to get the Nomad or Vault token, run again when the token expires.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"target": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "The token returned by the command, `nomad` or `vault`.",
+							ValidateFunc: validation.StringInSlice([]string{TokenCommandTargetNomad, TokenCommandTargetVault}, false),
+						},
+						"command": {
+							Type:        schema.TypeList,
+							Required:    true,
+							MinItems:    1,
+							Description: "The command and its arguments.",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"ttl": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "How long the token can be used, when the command does not return its expiration time.",
+						},
+					},
+				},
+			},
+			"http_auth": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("NOMAD_HTTP_AUTH", ""),
+				Description: "HTTP basic authentication credentials, in the form `user:pass` or `user`.",
+			},
+			"headers": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The headers to send with each Nomad request.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The header name",
+						},
+						"value": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Sensitive:   true,
+							Description: "The header value",
+						},
+					},
+				},
+			},
+			"retry": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Retries of the requests failing with transient errors.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"max_attempts": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      defaultRetryMaxAttempts,
+							Description:  "The maximum number of times a request is sent.",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"min_backoff": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     defaultRetryMinBackoff,
+							Description: "How long to wait before the first retry.",
+						},
+						"max_backoff": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     defaultRetryMaxBackoff,
+							Description: "The maximum time to wait between two retries.",
+						},
+					},
+				},
+			},
 		},
 
 		ConfigureFunc: providerConfigure,
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"nomad_acl_policy":  dataSourceAclPolicy(),
-			"nomad_acl_token":   dataSourceACLToken(),
-			"nomad_acl_tokens":  dataSourceACLTokens(),
-			"nomad_deployments": dataSourceDeployments(),
-			"nomad_job":         dataSourceJob(),
-			"nomad_job_parser":  dataSourceJobParser(),
-			"nomad_namespace":   dataSourceNamespace(),
-			"nomad_namespaces":  dataSourceNamespaces(),
-			"nomad_plugin":      dataSourcePlugin(),
-			"nomad_plugins":     dataSourcePlugins(),
-			"nomad_regions":     dataSourceRegions(),
-			"nomad_volumes":     dataSourceVolumes(),
+			"nomad_acl_policy":   dataSourceAclPolicy(),
+			"nomad_acl_token":    dataSourceACLToken(),
+			"nomad_acl_tokens":   dataSourceACLTokens(),
+			"nomad_deployments":  dataSourceDeployments(),
+			"nomad_job":          dataSourceJob(),
+			"nomad_job_parser":   dataSourceJobParser(),
+			"nomad_job_versions": dataSourceJobVersions(),
+			"nomad_namespace":    dataSourceNamespace(),
+			"nomad_namespaces":   dataSourceNamespaces(),
+			"nomad_plugin":       dataSourcePlugin(),
+			"nomad_plugins":      dataSourcePlugins(),
+			"nomad_regions":      dataSourceRegions(),
+			"nomad_volumes":      dataSourceVolumes(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
 			"nomad_acl_policy":          resourceACLPolicy(),
 			"nomad_acl_token":           resourceACLToken(),
 			"nomad_job":                 resourceJob(),
+			"nomad_job_dispatch":        resourceJobDispatch(),
+			"nomad_job_periodic_force":  resourceJobPeriodicForce(),
+			"nomad_job_revert":          resourceJobRevert(),
 			"nomad_namespace":           resourceNamespace(),
 			"nomad_quota_specification": resourceQuotaSpecification(),
 			"nomad_sentinel_policy":     resourceSentinelPolicy(),
 			"nomad_volume":              resourceVolume(),
 		},
 	}
+
+	for _, r := range provider.ResourcesMap {
+		r.Read = readUnlessAddressUnknown(r.Read)
+		r.Delete = deleteUnlessAddressUnknown(r.Delete)
+	}
+	for name, r := range provider.DataSourcesMap {
+		// nomad_job_parser can parse jobspecs without Nomad, it checks
+		// the address itself
+		if name == "nomad_job_parser" {
+			continue
+		}
+		r.Read = readUnlessAddressUnknown(r.Read)
+	}
+
+	return provider
+}
+
+// addressUnknown returns whether the address of the provider is not known
+// yet, for example when the cluster it targets is created in the same run.
+func addressUnknown(meta interface{}) bool {
+	providerConfig, ok := meta.(ProviderConfig)
+	return ok && providerConfig.addressUnknown
+}
+
+// readUnlessAddressUnknown wraps the Read function of a resource or a data
+// source so that nothing is read while the address of the provider is
+// unknown: resources keep their state as is and data sources have no value
+// until the address is known.
+func readUnlessAddressUnknown(read schema.ReadFunc) schema.ReadFunc {
+	return func(d *schema.ResourceData, meta interface{}) error {
+		if addressUnknown(meta) {
+			log.Printf("[WARN] address of the provider unknown, not reading %q from Nomad", d.Id())
+			return nil
+		}
+		return read(d, meta)
+	}
+}
+
+// deleteUnlessAddressUnknown wraps the Delete function of a resource so that
+// it fails while the address of the provider is unknown: the resource cannot
+// be destroyed, and removing it from the state would leave it running in the
+// cluster it was created in.
+func deleteUnlessAddressUnknown(delete schema.DeleteFunc) schema.DeleteFunc {
+	return func(d *schema.ResourceData, meta interface{}) error {
+		if addressUnknown(meta) {
+			return fmt.Errorf("cannot destroy %q: %s, destroy it before replacing the cluster it runs in", d.Id(), errAddressUnknown)
+		}
+		return delete(d, meta)
+	}
 }
 
 // Get gets the value of the stored token, if any
@@ -106,21 +323,86 @@ func getToken() (string, error) {
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	conf := api.DefaultConfig()
-	conf.Address = d.Get("address").(string)
+
+	// The address is unknown when it depends on a resource that is not
+	// created yet. An empty address falls back to NOMAD_ADDR and the
+	// default address.
+	address, addressKnown := d.GetOkExists("address")
+	if address.(string) != "" {
+		conf.Address = address.(string)
+	}
 	conf.Region = d.Get("region").(string)
-	conf.TLSConfig.CACert = d.Get("ca_file").(string)
-	conf.TLSConfig.ClientCert = d.Get("cert_file").(string)
-	conf.TLSConfig.ClientKey = d.Get("key_file").(string)
 	conf.SecretID = d.Get("secret_id").(string)
 
-	// Get the vault token from the conf, VAULT_TOKEN
-	// or ~/.vault-token (in that order)
-	var err error
-	vaultToken := d.Get("vault_token").(string)
-	if vaultToken == "" {
-		vaultToken, err = getToken()
-		if err != nil {
-			return nil, err
+	if err := configureTLS(conf.TLSConfig, d); err != nil {
+		return nil, err
+	}
+
+	conf.HttpAuth = nil
+	if auth := d.Get("http_auth").(string); auth != "" {
+		username, password := auth, ""
+		if parts := strings.SplitN(auth, ":", 2); len(parts) == 2 {
+			username, password = parts[0], parts[1]
+		}
+		conf.HttpAuth = &api.HttpBasicAuth{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	tokenCommands, err := providerTokenCommands(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the Nomad token from secret_id, NOMAD_TOKEN, secret_id_file or
+	// the token command (in that order). Tokens that are not static are
+	// added to the requests by the HTTP client.
+	var nomadToken *tokenSource
+	if conf.SecretID == "" {
+		if path := d.Get("secret_id_file").(string); path != "" {
+			nomadToken = fileTokenSource(path)
+		} else {
+			nomadToken = tokenCommands[TokenCommandTargetNomad]
+		}
+	}
+
+	retry, err := providerRetryConfig(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HTTP client is only set up on the first request, so that the
+	// provider can be configured while the cluster and its certificates
+	// do not exist yet.
+	tlsConfig, headers := conf.TLSConfig, providerHeaders(d)
+	conf.HttpClient = &http.Client{
+		Transport: &lazyRoundTripper{
+			init: func() (http.RoundTripper, error) {
+				if !addressKnown {
+					return nil, errAddressUnknown
+				}
+				return providerTransport(tlsConfig, headers, nomadToken, retry)
+			},
+		},
+	}
+
+	// Get the vault token from the conf, VAULT_TOKEN, vault_token_file,
+	// the token command or ~/.vault-token (in that order). The token helper
+	// is only called when a job is registered.
+	var vaultToken *tokenSource
+	if token := d.Get("vault_token").(string); token != "" {
+		vaultToken = staticTokenSource(token)
+	} else if path := d.Get("vault_token_file").(string); path != "" {
+		vaultToken = fileTokenSource(path)
+	} else if source, ok := tokenCommands[TokenCommandTargetVault]; ok {
+		vaultToken = source
+	} else {
+		vaultToken = &tokenSource{
+			fetch: func() (string, time.Time, error) {
+				token, err := getToken()
+				return token, time.Time{}, err
+			},
 		}
 	}
 
@@ -129,10 +411,219 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		return nil, fmt.Errorf("failed to configure Nomad API: %s", err)
 	}
 
+	consulToken := d.Get("consul_token").(string)
+
 	res := ProviderConfig{
-		client:     client,
-		vaultToken: &vaultToken,
+		client:         client,
+		vaultToken:     vaultToken,
+		consulToken:    &consulToken,
+		retry:          retry,
+		addressUnknown: !addressKnown,
 	}
 
 	return res, nil
 }
+
+// configureTLS sets the TLS configuration of the Nomad client. Each of the
+// CA certificate, client certificate and client key can be given either as
+// a path or as PEM, and the client certificate and key go together.
+func configureTLS(tlsConfig *api.TLSConfig, d *schema.ResourceData) error {
+	tlsConfig.CACert = d.Get("ca_file").(string)
+	tlsConfig.CACertPEM = []byte(d.Get("ca_pem").(string))
+	tlsConfig.ClientCert = d.Get("cert_file").(string)
+	tlsConfig.ClientCertPEM = []byte(d.Get("cert_pem").(string))
+	tlsConfig.ClientKey = d.Get("key_file").(string)
+	tlsConfig.ClientKeyPEM = []byte(d.Get("key_pem").(string))
+	tlsConfig.TLSServerName = d.Get("tls_server_name").(string)
+	tlsConfig.Insecure = d.Get("skip_verify").(bool)
+
+	for _, pair := range [][2]string{{"ca_file", "ca_pem"}, {"cert_file", "cert_pem"}, {"key_file", "key_pem"}} {
+		if d.Get(pair[0]).(string) != "" && d.Get(pair[1]).(string) != "" {
+			return fmt.Errorf("only one of %s and %s can be set", pair[0], pair[1])
+		}
+	}
+
+	hasCert := tlsConfig.ClientCert != "" || len(tlsConfig.ClientCertPEM) > 0
+	hasKey := tlsConfig.ClientKey != "" || len(tlsConfig.ClientKeyPEM) > 0
+	if hasCert != hasKey {
+		return fmt.Errorf("the client certificate (cert_file or cert_pem) and key (key_file or key_pem) must be set together")
+	}
+
+	return nil
+}
+
+// loadClientCertificate reads the client certificate or key file when the
+// other one is given as PEM, since the Nomad client expects either two
+// paths or two PEM.
+func loadClientCertificate(tlsConfig *api.TLSConfig) error {
+	if tlsConfig.ClientCert != "" && len(tlsConfig.ClientKeyPEM) > 0 {
+		pem, err := ioutil.ReadFile(tlsConfig.ClientCert)
+		if err != nil {
+			return fmt.Errorf("error reading cert_file: %s", err)
+		}
+		tlsConfig.ClientCert, tlsConfig.ClientCertPEM = "", pem
+	}
+	if tlsConfig.ClientKey != "" && len(tlsConfig.ClientCertPEM) > 0 {
+		pem, err := ioutil.ReadFile(tlsConfig.ClientKey)
+		if err != nil {
+			return fmt.Errorf("error reading key_file: %s", err)
+		}
+		tlsConfig.ClientKey, tlsConfig.ClientKeyPEM = "", pem
+	}
+
+	return nil
+}
+
+// providerHeaders returns the headers set in the headers blocks of the
+// provider.
+func providerHeaders(d *schema.ResourceData) http.Header {
+	headers := make(http.Header)
+	for _, raw := range d.Get("headers").([]interface{}) {
+		h, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		headers.Add(h["name"].(string), h["value"].(string))
+	}
+	return headers
+}
+
+// providerTransport returns an HTTP transport configured like the one of
+// the default client of the Nomad API, that adds headers and the Nomad token
+// to every request and retries the requests failing with transient errors.
+func providerTransport(tlsConfig *api.TLSConfig, headers http.Header, nomadToken *tokenSource, retry retryConfig) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// The Nomad API ignores its TLS configuration when it is given an HTTP
+	// client, so it has to be applied here.
+	if err := loadClientCertificate(tlsConfig); err != nil {
+		return nil, err
+	}
+	if err := api.ConfigureTLS(&http.Client{Transport: transport}, tlsConfig); err != nil {
+		return nil, err
+	}
+
+	var rt http.RoundTripper = transport
+	if retry.maxAttempts > 1 {
+		rt = &retryRoundTripper{
+			config: retry,
+			next:   rt,
+		}
+	}
+
+	if len(headers) == 0 && nomadToken == nil {
+		return rt, nil
+	}
+	return &providerRoundTripper{
+		headers:    headers,
+		nomadToken: nomadToken,
+		next:       rt,
+	}, nil
+}
+
+// lazyRoundTripper is an http.RoundTripper that creates the actual
+// http.RoundTripper on the first request. If it cannot be created, for
+// example because a token command failed, it is created again on the next
+// request.
+type lazyRoundTripper struct {
+	init func() (http.RoundTripper, error)
+
+	mu sync.Mutex
+	rt http.RoundTripper
+}
+
+func (l *lazyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt, err := l.roundTripper()
+	if err != nil {
+		return nil, err
+	}
+	return rt.RoundTrip(req)
+}
+
+func (l *lazyRoundTripper) roundTripper() (http.RoundTripper, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rt != nil {
+		return l.rt, nil
+	}
+	rt, err := l.init()
+	if err == errAddressUnknown {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Nomad API: %s", err)
+	}
+	l.rt = rt
+	return rt, nil
+}
+
+// providerRoundTripper is an http.RoundTripper that adds headers and the
+// Nomad token to the requests.
+type providerRoundTripper struct {
+	headers    http.Header
+	nomadToken *tokenSource
+	next       http.RoundTripper
+}
+
+func (rt *providerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range rt.headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = values[0]
+			continue
+		}
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	if rt.nomadToken != nil && req.Header.Get("X-Nomad-Token") == "" {
+		token, err := rt.nomadToken.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error getting Nomad token: %s", err)
+		}
+		req.Header.Set("X-Nomad-Token", token)
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+// providerTokenCommands returns the token sources of the token_command
+// blocks, by target.
+func providerTokenCommands(d *schema.ResourceData) (map[string]*tokenSource, error) {
+	sources := make(map[string]*tokenSource)
+	for _, raw := range d.Get("token_command").([]interface{}) {
+		c, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		target := c["target"].(string)
+		if _, ok := sources[target]; ok {
+			return nil, fmt.Errorf("only one token_command can be set for target %q", target)
+		}
+
+		var command []string
+		for _, arg := range c["command"].([]interface{}) {
+			s, _ := arg.(string)
+			command = append(command, s)
+		}
+
+		var ttl time.Duration
+		if v := c["ttl"].(string); v != "" {
+			var err error
+			if ttl, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid ttl for token_command %q: %s", target, err)
+			}
+		}
+
+		sources[target] = commandTokenSource(command, ttl)
+	}
+	return sources, nil
+}
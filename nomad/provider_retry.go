@@ -0,0 +1,231 @@
+package nomad
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// Default values of the retry block of the provider.
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryMinBackoff  = "1s"
+	defaultRetryMaxBackoff  = "30s"
+)
+
+// retryConfig is the configuration of the retries of the requests made to
+// Nomad.
+type retryConfig struct {
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// backoff returns how long to wait after the given failed attempt, starting
+// at 1. The wait doubles after each attempt, up to maxBackoff.
+func (c retryConfig) backoff(attempt int) time.Duration {
+	backoff := c.minBackoff
+	for i := 1; i < attempt && backoff < c.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+	return backoff
+}
+
+// providerRetryConfig returns the configuration of the retry block of the
+// provider. Requests are not retried when there is no retry block.
+func providerRetryConfig(d *schema.ResourceData) (retryConfig, error) {
+	raw, ok := d.Get("retry").([]interface{})
+	if !ok || len(raw) == 0 || raw[0] == nil {
+		return retryConfig{maxAttempts: 1}, nil
+	}
+	r := raw[0].(map[string]interface{})
+
+	config := retryConfig{
+		maxAttempts: r["max_attempts"].(int),
+	}
+	var err error
+	if config.minBackoff, err = time.ParseDuration(r["min_backoff"].(string)); err != nil {
+		return config, fmt.Errorf("invalid min_backoff for retry: %s", err)
+	}
+	if config.maxBackoff, err = time.ParseDuration(r["max_backoff"].(string)); err != nil {
+		return config, fmt.Errorf("invalid max_backoff for retry: %s", err)
+	}
+	if config.minBackoff > config.maxBackoff {
+		return config, fmt.Errorf("min_backoff for retry must not be greater than max_backoff")
+	}
+	return config, nil
+}
+
+// retryRoundTripper is an http.RoundTripper that retries the requests
+// failing with transient errors.
+type retryRoundTripper struct {
+	config retryConfig
+	next   http.RoundTripper
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A request whose body cannot be sent again is never retried
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return rt.next.RoundTrip(req)
+	}
+
+	idempotent := requestIsIdempotent(req)
+	for attempt := 1; ; attempt++ {
+		resp, err := rt.next.RoundTrip(req)
+		if attempt >= rt.config.maxAttempts {
+			return resp, err
+		}
+
+		reason := retryReason(resp, err, idempotent)
+		if reason == "" {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		backoff := rt.config.backoff(attempt)
+		log.Printf("[WARN] %s %s failed (%s), retrying in %s (attempt %d of %d)",
+			req.Method, req.URL.Path, reason, backoff, attempt+1, rt.config.maxAttempts)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+var (
+	// jobRegisterPathRegexp matches the path of the job registrations.
+	jobRegisterPathRegexp = regexp.MustCompile(`^/v1/(jobs|job/[^/]+)$`)
+
+	// idempotentWritePathRegexp matches the paths of the write endpoints
+	// that can be sent again: they replace an object by its new version,
+	// or they do not change anything.
+	idempotentWritePathRegexp = regexp.MustCompile(`^/v1/(` +
+		`acl/policy/[^/]+|` +
+		`acl/token/[^/]+|` +
+		`namespaces?(/[^/]+)?|` +
+		`quotas?(/[^/]+)?|` +
+		`sentinel/policy/[^/]+|` +
+		`volume/csi/[^/]+|` +
+		`jobs/parse|` +
+		`job/[^/]+/plan|` +
+		`validate/job` +
+		`)$`)
+)
+
+// requestIsIdempotent returns whether a request can be sent again when it is
+// not known if Nomad applied it. Writes are not idempotent unless they are
+// known to be: each job dispatch, scaling or deployment promotion applied
+// twice has another effect. Job registrations are only idempotent when they
+// are enforced against the modify index of the job, as a registration that
+// was already applied then fails instead of creating a new version.
+func requestIsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+
+	path := req.URL.EscapedPath()
+	switch {
+	case jobRegisterPathRegexp.MatchString(path):
+		return requestEnforcesIndex(req)
+	case idempotentWritePathRegexp.MatchString(path):
+		return true
+	default:
+		return false
+	}
+}
+
+// requestEnforcesIndex returns whether the body of a job registration sets
+// EnforceIndex.
+func requestEnforcesIndex(req *http.Request) bool {
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+
+	var register struct {
+		EnforceIndex bool
+	}
+	if err := json.NewDecoder(body).Decode(&register); err != nil {
+		return false
+	}
+	return register.EnforceIndex
+}
+
+// retryReason returns why a request should be sent again, or "" if it
+// should not. Requests that are not idempotent are only sent again when
+// Nomad did not apply them: when the connection could not be established,
+// when they were rate limited or when the cluster had no leader.
+func retryReason(resp *http.Response, err error, idempotent bool) string {
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return err.Error()
+		}
+		if !idempotent {
+			return ""
+		}
+		var netErr net.Error
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+			(errors.As(err, &netErr) && netErr.Timeout()) {
+			return err.Error()
+		}
+		return ""
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return resp.Status
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		if idempotent {
+			return resp.Status
+		}
+	case http.StatusInternalServerError:
+		// Nomad also reports invalid requests with a 500, so only the
+		// errors caused by leader elections are retried.
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return ""
+		}
+		msg := strings.TrimSpace(string(body))
+		if strings.Contains(msg, "No cluster leader") || (idempotent && strings.Contains(msg, "leadership lost")) {
+			return msg
+		}
+	}
+	return ""
+}
@@ -0,0 +1,308 @@
+package nomad
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryConfig_backoff(t *testing.T) {
+	config := retryConfig{
+		maxAttempts: 10,
+		minBackoff:  time.Second,
+		maxBackoff:  5 * time.Second,
+	}
+	require.Equal(t, time.Second, config.backoff(1))
+	require.Equal(t, 2*time.Second, config.backoff(2))
+	require.Equal(t, 4*time.Second, config.backoff(3))
+	require.Equal(t, 5*time.Second, config.backoff(4))
+	require.Equal(t, 5*time.Second, config.backoff(100))
+}
+
+func TestProvider_retryConfig(t *testing.T) {
+	providerSchema := Provider().(*schema.Provider).Schema
+
+	d := schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{})
+	config, err := providerRetryConfig(d)
+	require.NoError(t, err)
+	require.Equal(t, retryConfig{maxAttempts: 1}, config)
+
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"retry": []interface{}{
+			map[string]interface{}{"max_backoff": "1m"},
+		},
+	})
+	config, err = providerRetryConfig(d)
+	require.NoError(t, err)
+	require.Equal(t, retryConfig{
+		maxAttempts: defaultRetryMaxAttempts,
+		minBackoff:  time.Second,
+		maxBackoff:  time.Minute,
+	}, config)
+
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"retry": []interface{}{
+			map[string]interface{}{"min_backoff": "1m", "max_backoff": "1s"},
+		},
+	})
+	_, err = providerRetryConfig(d)
+	require.Error(t, err)
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		message  string
+		attempts int
+	}{
+		{
+			name:     "read on unavailable server",
+			method:   http.MethodGet,
+			path:     "/v1/jobs",
+			status:   http.StatusServiceUnavailable,
+			attempts: 3,
+		},
+		{
+			name:     "read on bad gateway",
+			method:   http.MethodGet,
+			path:     "/v1/job/example",
+			status:   http.StatusBadGateway,
+			attempts: 3,
+		},
+		{
+			name:     "read on invalid request",
+			method:   http.MethodGet,
+			path:     "/v1/job/example",
+			status:   http.StatusInternalServerError,
+			message:  "job not found",
+			attempts: 1,
+		},
+		{
+			name:     "registration without leader",
+			method:   http.MethodPut,
+			path:     "/v1/jobs",
+			body:     `{"Job": {"ID": "example"}}`,
+			status:   http.StatusInternalServerError,
+			message:  "No cluster leader",
+			attempts: 3,
+		},
+		{
+			name:     "registration on bad gateway",
+			method:   http.MethodPut,
+			path:     "/v1/job/example",
+			body:     `{"Job": {"ID": "example"}}`,
+			status:   http.StatusBadGateway,
+			attempts: 1,
+		},
+		{
+			name:     "registration enforcing the modify index on bad gateway",
+			method:   http.MethodPut,
+			path:     "/v1/job/example",
+			body:     `{"Job": {"ID": "example"}, "EnforceIndex": true, "JobModifyIndex": 10}`,
+			status:   http.StatusBadGateway,
+			attempts: 3,
+		},
+		{
+			name:     "registration enforcing the modify index on conflict",
+			method:   http.MethodPut,
+			path:     "/v1/job/example",
+			body:     `{"Job": {"ID": "example"}, "EnforceIndex": true, "JobModifyIndex": 10}`,
+			status:   http.StatusInternalServerError,
+			message:  "Enforcing job modify index 10: job exists with conflicting job modify index: 12",
+			attempts: 1,
+		},
+		{
+			name:     "dispatch on bad gateway",
+			method:   http.MethodPut,
+			path:     "/v1/job/example/dispatch",
+			body:     `{}`,
+			status:   http.StatusBadGateway,
+			attempts: 1,
+		},
+		{
+			name:     "dispatch when rate limited",
+			method:   http.MethodPut,
+			path:     "/v1/job/example/dispatch",
+			body:     `{}`,
+			status:   http.StatusTooManyRequests,
+			attempts: 3,
+		},
+		{
+			name:     "scaling on bad gateway",
+			method:   http.MethodPost,
+			path:     "/v1/job/example/scale",
+			body:     `{"Count": 3}`,
+			status:   http.StatusBadGateway,
+			attempts: 1,
+		},
+		{
+			name:     "deployment promotion on bad gateway",
+			method:   http.MethodPost,
+			path:     "/v1/deployment/promote/example",
+			body:     `{"All": true}`,
+			status:   http.StatusBadGateway,
+			attempts: 1,
+		},
+		{
+			name:     "namespace update on bad gateway",
+			method:   http.MethodPut,
+			path:     "/v1/namespace",
+			body:     `{"Name": "example"}`,
+			status:   http.StatusBadGateway,
+			attempts: 3,
+		},
+		{
+			name:     "policy update on bad gateway",
+			method:   http.MethodPost,
+			path:     "/v1/acl/policy/example",
+			body:     `{"Name": "example"}`,
+			status:   http.StatusBadGateway,
+			attempts: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, tc.body, string(body))
+
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.message))
+			}))
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: &retryRoundTripper{
+					config: retryConfig{
+						maxAttempts: 3,
+						minBackoff:  time.Millisecond,
+						maxBackoff:  time.Millisecond,
+					},
+					next: http.DefaultTransport,
+				},
+			}
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tc.attempts, attempts)
+			require.Equal(t, tc.status, resp.StatusCode)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.message, string(body))
+		})
+	}
+}
+
+func TestProvider_retry(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("No cluster leader"))
+			return
+		}
+		w.Write([]byte(`["global"]`))
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":     server.URL,
+		"vault_token": "vault",
+		"retry": []interface{}{
+			map[string]interface{}{"min_backoff": "1ms", "max_backoff": "1ms"},
+		},
+	})
+	meta, err := providerConfigure(d)
+	require.NoError(t, err)
+
+	regions, err := meta.(ProviderConfig).client.Regions().List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"global"}, regions)
+	require.Equal(t, 3, attempts)
+
+	// Without the retry block, the error is returned as is
+	attempts = 0
+	d = schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":     server.URL,
+		"vault_token": "vault",
+	})
+	meta, err = providerConfigure(d)
+	require.NoError(t, err)
+
+	_, err = meta.(ProviderConfig).client.Regions().List()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No cluster leader")
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryReason_connectionErrors(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	require.NotEmpty(t, retryReason(nil, dialErr, true))
+	require.NotEmpty(t, retryReason(nil, dialErr, false))
+
+	// The request may have been applied when the connection is reset
+	resetErr := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	require.NotEmpty(t, retryReason(nil, resetErr, true))
+	require.Empty(t, retryReason(nil, resetErr, false))
+
+	require.Empty(t, retryReason(nil, errors.New("x509: certificate signed by unknown authority"), true))
+}
+
+func TestRequestIsIdempotent(t *testing.T) {
+	testCases := []struct {
+		method     string
+		path       string
+		body       string
+		idempotent bool
+	}{
+		{http.MethodGet, "/v1/jobs", "", true},
+		{http.MethodDelete, "/v1/job/example", "", true},
+		{http.MethodPut, "/v1/job/example", `{"Job": {}}`, false},
+		{http.MethodPut, "/v1/job/example", `{"Job": {}, "EnforceIndex": true}`, true},
+		{http.MethodPut, "/v1/job/example/plan", `{}`, true},
+		{http.MethodPost, "/v1/jobs/parse", `{}`, true},
+		{http.MethodPost, "/v1/job/example/scale", `{}`, false},
+		{http.MethodPost, "/v1/job/example/dispatch", `{}`, false},
+		{http.MethodPost, "/v1/job/example/periodic/force", `{}`, false},
+		{http.MethodPost, "/v1/deployment/promote/example", `{}`, false},
+		{http.MethodPost, "/v1/deployment/fail/example", `{}`, false},
+		{http.MethodPost, "/v1/deployment/pause/example", `{}`, false},
+		{http.MethodPost, "/v1/search", `{}`, false},
+		{http.MethodPost, "/v1/acl/token", `{}`, false},
+		{http.MethodPost, "/v1/acl/token/example", `{}`, true},
+		{http.MethodPost, "/v1/acl/bootstrap", `{}`, false},
+		{http.MethodPut, "/v1/acl/policy/example", `{}`, true},
+		{http.MethodPut, "/v1/quota", `{}`, true},
+		{http.MethodPut, "/v1/sentinel/policy/example", `{}`, true},
+		{http.MethodPut, "/v1/volume/csi/example", `{}`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "http://127.0.0.1:4646"+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			require.Equal(t, tc.idempotent, requestIsIdempotent(req))
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package nomad
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/require"
 )
 
 // How to run the acceptance tests for this provider:
@@ -38,6 +44,192 @@ func TestProvider_impl(t *testing.T) {
 	var _ terraform.ResourceProvider = Provider()
 }
 
+func TestProvider_configureTLS(t *testing.T) {
+	providerSchema := Provider().(*schema.Provider).Schema
+
+	d := schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"ca_pem":          "ca",
+		"cert_pem":        "cert",
+		"key_pem":         "key",
+		"tls_server_name": "server.global.nomad",
+		"skip_verify":     true,
+	})
+	tlsConfig := &api.TLSConfig{}
+	require.NoError(t, configureTLS(tlsConfig, d))
+	require.Equal(t, &api.TLSConfig{
+		CACertPEM:     []byte("ca"),
+		ClientCertPEM: []byte("cert"),
+		ClientKeyPEM:  []byte("key"),
+		TLSServerName: "server.global.nomad",
+		Insecure:      true,
+	}, tlsConfig)
+
+	// A certificate file with a PEM key is read
+	certFile, err := ioutil.TempFile("", "cert")
+	require.NoError(t, err)
+	defer os.Remove(certFile.Name())
+	_, err = certFile.WriteString("cert")
+	require.NoError(t, err)
+	require.NoError(t, certFile.Close())
+
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"cert_file": certFile.Name(),
+		"key_pem":   "key",
+	})
+	tlsConfig = &api.TLSConfig{}
+	require.NoError(t, configureTLS(tlsConfig, d))
+	require.NoError(t, loadClientCertificate(tlsConfig))
+	require.Equal(t, "", tlsConfig.ClientCert)
+	require.Equal(t, []byte("cert"), tlsConfig.ClientCertPEM)
+	require.Equal(t, []byte("key"), tlsConfig.ClientKeyPEM)
+
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"ca_file": "/etc/nomad/ca.pem",
+		"ca_pem":  "ca",
+	})
+	require.EqualError(t, configureTLS(&api.TLSConfig{}, d), "only one of ca_file and ca_pem can be set")
+
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"cert_pem": "cert",
+	})
+	require.Error(t, configureTLS(&api.TLSConfig{}, d))
+}
+
+func TestProvider_headers(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":     server.URL,
+		"vault_token": "vault",
+		"http_auth":   "user:pass",
+		"headers": []interface{}{
+			map[string]interface{}{"name": "X-Proxy-Token", "value": "secret"},
+			map[string]interface{}{"name": "X-Team", "value": "a"},
+			map[string]interface{}{"name": "X-Team", "value": "b"},
+		},
+	})
+	meta, err := providerConfigure(d)
+	require.NoError(t, err)
+
+	_, err = meta.(ProviderConfig).client.Regions().List()
+	require.NoError(t, err)
+	require.Equal(t, "secret", received.Header.Get("X-Proxy-Token"))
+	require.Equal(t, []string{"a", "b"}, received.Header["X-Team"])
+	username, password, ok := received.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", username)
+	require.Equal(t, "pass", password)
+}
+
+// unknownValue is how Terraform represents a value that is not known yet.
+const unknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+func TestProvider_lazyConfigure(t *testing.T) {
+	providerSchema := Provider().(*schema.Provider).Schema
+
+	// An unknown address does not fail the configuration, only the requests
+	d := schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"address":     unknownValue,
+		"vault_token": "vault",
+	})
+	meta, err := providerConfigure(d)
+	require.NoError(t, err)
+	require.True(t, meta.(ProviderConfig).addressUnknown)
+	_, err = meta.(ProviderConfig).client.Regions().List()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errAddressUnknown.Error())
+
+	// An empty address falls back to NOMAD_ADDR
+	os.Setenv("NOMAD_ADDR", "http://127.0.0.1:4747")
+	defer os.Unsetenv("NOMAD_ADDR")
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"address":     "",
+		"vault_token": "vault",
+	})
+	meta, err = providerConfigure(d)
+	require.NoError(t, err)
+	require.False(t, meta.(ProviderConfig).addressUnknown)
+	require.Equal(t, "http://127.0.0.1:4747", meta.(ProviderConfig).client.Address())
+
+	// Missing TLS files and tokens are only read on first use
+	d = schema.TestResourceDataRaw(t, providerSchema, map[string]interface{}{
+		"address":        "https://127.0.0.1:4646",
+		"ca_file":        "/does/not/exist/ca.pem",
+		"secret_id_file": "/does/not/exist/token",
+		"vault_token":    "vault",
+	})
+	meta, err = providerConfigure(d)
+	require.NoError(t, err)
+	require.False(t, meta.(ProviderConfig).addressUnknown)
+	_, err = meta.(ProviderConfig).client.Regions().List()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to configure Nomad API")
+
+	// Reads keep the state as is while the address is unknown
+	r := Provider().(*schema.Provider).ResourcesMap["nomad_job"]
+	state := r.TestResourceData()
+	state.SetId("example")
+	require.NoError(t, r.Read(state, ProviderConfig{addressUnknown: true}))
+	require.Equal(t, "example", state.Id())
+
+	// Destroying fails instead of leaving the job running
+	err = r.Delete(state, ProviderConfig{addressUnknown: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "destroy it before replacing the cluster")
+	require.Equal(t, "example", state.Id())
+
+	// Data sources are not read either
+	ds := Provider().(*schema.Provider).DataSourcesMap["nomad_job"]
+	data := ds.TestResourceData()
+	require.NoError(t, ds.Read(data, ProviderConfig{addressUnknown: true}))
+
+	// except the job parser in local mode, which does not need Nomad
+	parser := Provider().(*schema.Provider).DataSourcesMap["nomad_job_parser"]
+	data = parser.TestResourceData()
+	require.NoError(t, data.Set("hcl", `job "example" {}`))
+	require.NoError(t, data.Set("mode", JobParserModeLocal))
+	require.NoError(t, parser.Read(data, ProviderConfig{addressUnknown: true}))
+	require.Equal(t, "example", data.Id())
+	require.NotEmpty(t, data.Get("json"))
+
+	data = parser.TestResourceData()
+	require.NoError(t, data.Set("hcl", `job "example" {}`))
+	require.NoError(t, data.Set("mode", JobParserModeRemote))
+	require.NoError(t, parser.Read(data, ProviderConfig{addressUnknown: true}))
+	require.Equal(t, "", data.Id())
+}
+
+func TestLazyRoundTripper_retriesInit(t *testing.T) {
+	var calls int
+	rt := &lazyRoundTripper{
+		init: func() (http.RoundTripper, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("token command failed")
+			}
+			return http.DefaultTransport, nil
+		},
+	}
+
+	_, err := rt.roundTripper()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to configure Nomad API: token command failed")
+
+	next, err := rt.roundTripper()
+	require.NoError(t, err)
+	require.Equal(t, http.DefaultTransport, next)
+
+	// The round tripper is only created once it succeeded
+	_, err = rt.roundTripper()
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
 var testProvider *schema.Provider
 var testProviders map[string]terraform.ResourceProvider
 
@@ -0,0 +1,141 @@
+package nomad
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Targets of the token_command blocks of the provider.
+const (
+	TokenCommandTargetNomad = "nomad"
+	TokenCommandTargetVault = "vault"
+)
+
+// tokenExpiryWindow is how long before it expires a token is fetched again,
+// so that it does not expire while a request is in flight.
+const tokenExpiryWindow = 10 * time.Second
+
+// tokenSource returns a token, which is fetched on first use and fetched
+// again once it expires. A nil tokenSource returns an empty token.
+type tokenSource struct {
+	fetch func() (string, time.Time, error)
+
+	mu        sync.Mutex
+	fetched   bool
+	token     string
+	expiresAt time.Time
+}
+
+// Token returns the current token, fetching it if needed.
+func (s *tokenSource) Token() (string, error) {
+	if s == nil {
+		return "", nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetched && (s.expiresAt.IsZero() || time.Now().Add(tokenExpiryWindow).Before(s.expiresAt)) {
+		return s.token, nil
+	}
+
+	token, expiresAt, err := s.fetch()
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiresAt, s.fetched = token, expiresAt, true
+	return token, nil
+}
+
+// staticTokenSource returns a tokenSource for a token that never changes.
+func staticTokenSource(token string) *tokenSource {
+	return &tokenSource{
+		fetch: func() (string, time.Time, error) {
+			return token, time.Time{}, nil
+		},
+	}
+}
+
+// tokenFileCheckInterval is how often a token file is read again.
+const tokenFileCheckInterval = 5 * time.Second
+
+// fileTokenSource returns a tokenSource reading the token from a file. The
+// file is read again every tokenFileCheckInterval, so that tokens rotated by
+// an agent are picked up.
+func fileTokenSource(path string) *tokenSource {
+	return &tokenSource{
+		fetch: func() (string, time.Time, error) {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("error reading token file: %s", err)
+			}
+			token := strings.TrimSpace(string(content))
+			if token == "" {
+				return "", time.Time{}, fmt.Errorf("token file %q is empty", path)
+			}
+			return token, time.Now().Add(tokenExpiryWindow + tokenFileCheckInterval), nil
+		},
+	}
+}
+
+// commandTokenSource returns a tokenSource running a command to get the
+// token. The command writes either the token or a JSON object with the
+// token and its expiration time to its standard output, for example
+// {"token": "...", "expires_at": "2020-08-24T10:00:00Z"}. When the output
+// has no expiration time, the token is fetched again after ttl, or never if
+// ttl is 0.
+func commandTokenSource(command []string, ttl time.Duration) *tokenSource {
+	return &tokenSource{
+		fetch: func() (string, time.Time, error) {
+			return runTokenCommand(command, ttl)
+		},
+	}
+}
+
+func runTokenCommand(command []string, ttl time.Duration) (string, time.Time, error) {
+	if len(command) == 0 || command[0] == "" {
+		return "", time.Time{}, fmt.Errorf("token command is empty")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", time.Time{}, fmt.Errorf("error running token command %q: %s: %s", command[0], err, msg)
+		}
+		return "", time.Time{}, fmt.Errorf("error running token command %q: %s", command[0], err)
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	if strings.HasPrefix(output, "{") {
+		var result struct {
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			return "", time.Time{}, fmt.Errorf("error parsing the output of token command %q: %s", command[0], err)
+		}
+		output = result.Token
+		if !result.ExpiresAt.IsZero() {
+			expiresAt = result.ExpiresAt
+		}
+	}
+
+	if output == "" {
+		return "", time.Time{}, fmt.Errorf("token command %q returned an empty token", command[0])
+	}
+	return output, expiresAt, nil
+}
@@ -0,0 +1,142 @@
+package nomad
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSource(t *testing.T) {
+	var source *tokenSource
+	token, err := source.Token()
+	require.NoError(t, err)
+	require.Equal(t, "", token)
+
+	fetches := 0
+	expiresAt := time.Now().Add(time.Hour)
+	source = &tokenSource{
+		fetch: func() (string, time.Time, error) {
+			fetches++
+			return "token", expiresAt, nil
+		},
+	}
+	for i := 0; i < 3; i++ {
+		token, err = source.Token()
+		require.NoError(t, err)
+		require.Equal(t, "token", token)
+	}
+	require.Equal(t, 1, fetches)
+
+	// The token is fetched again when it is about to expire
+	expiresAt = time.Now().Add(tokenExpiryWindow / 2)
+	source.expiresAt = expiresAt
+	_, err = source.Token()
+	require.NoError(t, err)
+	_, err = source.Token()
+	require.NoError(t, err)
+	require.Equal(t, 3, fetches)
+}
+
+func TestRunTokenCommand(t *testing.T) {
+	token, expiresAt, err := runTokenCommand([]string{"echo", " secret "}, 0)
+	require.NoError(t, err)
+	require.Equal(t, "secret", token)
+	require.True(t, expiresAt.IsZero())
+
+	token, expiresAt, err = runTokenCommand([]string{"echo", "secret"}, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "secret", token)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 5*time.Second)
+
+	token, expiresAt, err = runTokenCommand([]string{"echo", `{"token": "secret", "expires_at": "2020-08-24T10:00:00Z"}`}, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "secret", token)
+	require.Equal(t, time.Date(2020, 8, 24, 10, 0, 0, 0, time.UTC), expiresAt.UTC())
+
+	_, _, err = runTokenCommand([]string{"sh", "-c", "echo denied >&2; exit 1"}, 0)
+	require.EqualError(t, err, `error running token command "sh": exit status 1: denied`)
+
+	_, _, err = runTokenCommand([]string{"true"}, 0)
+	require.EqualError(t, err, `token command "true" returned an empty token`)
+}
+
+func TestProvider_tokenFromFileAndCommand(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Nomad-Token")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "nomad-token")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+
+	d := schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":        server.URL,
+		"secret_id_file": tokenFile,
+		"token_command": []interface{}{
+			map[string]interface{}{
+				"target":  "vault",
+				"command": []interface{}{"echo", "vault-token"},
+			},
+		},
+	})
+	meta, err := providerConfigure(d)
+	require.NoError(t, err)
+	providerConfig := meta.(ProviderConfig)
+
+	_, err = providerConfig.client.Regions().List()
+	require.NoError(t, err)
+	require.Equal(t, "file-token", received)
+
+	vaultToken, err := providerConfig.vaultToken.Token()
+	require.NoError(t, err)
+	require.Equal(t, "vault-token", vaultToken)
+
+	d = schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":     server.URL,
+		"vault_token": "vault",
+		"token_command": []interface{}{
+			map[string]interface{}{
+				"target":  "nomad",
+				"command": []interface{}{"echo", "command-token"},
+			},
+		},
+	})
+	meta, err = providerConfigure(d)
+	require.NoError(t, err)
+
+	_, err = meta.(ProviderConfig).client.Regions().List()
+	require.NoError(t, err)
+	require.Equal(t, "command-token", received)
+
+	// The Vault token file takes precedence over the command
+	vaultTokenFile := filepath.Join(dir, "vault-token")
+	require.NoError(t, ioutil.WriteFile(vaultTokenFile, []byte("vault-file-token\n"), 0600))
+	d = schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"address":          server.URL,
+		"vault_token_file": vaultTokenFile,
+		"token_command": []interface{}{
+			map[string]interface{}{
+				"target":  "vault",
+				"command": []interface{}{"echo", "vault-token"},
+			},
+		},
+	})
+	meta, err = providerConfigure(d)
+	require.NoError(t, err)
+
+	vaultToken, err = meta.(ProviderConfig).vaultToken.Token()
+	require.NoError(t, err)
+	require.Equal(t, "vault-file-token", vaultToken)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
@@ -13,8 +14,7 @@ import (
 	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-
-	"github.com/hashicorp/terraform-provider-nomad/nomad/core/jobspec"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceJob() *schema.Resource {
@@ -22,7 +22,10 @@ func resourceJob() *schema.Resource {
 		Create: resourceJobRegister,
 		Update: resourceJobRegister,
 		Delete: resourceJobDeregister,
-		Read:   resourceJobRead,
+		Read:   resourceJobRefresh,
+		Importer: &schema.ResourceImporter{
+			State: resourceJobImportState,
+		},
 
 		CustomizeDiff: resourceJobCustomizeDiff,
 
@@ -34,12 +37,32 @@ func resourceJob() *schema.Resource {
 				DiffSuppressFunc: jobspecDiffSuppress,
 			},
 
+			"vault_token": {
+				Description: "Vault token used when registering the job, overrides the vault_token of the provider.",
+				Optional:    true,
+				Sensitive:   true,
+				Type:        schema.TypeString,
+			},
+
+			"consul_token": {
+				Description: "Consul token used when registering the job, overrides the consul_token of the provider.",
+				Optional:    true,
+				Sensitive:   true,
+				Type:        schema.TypeString,
+			},
+
 			"policy_override": {
 				Description: "Override any soft-mandatory Sentinel policies that fail.",
 				Optional:    true,
 				Type:        schema.TypeBool,
 			},
 
+			"fail_on_placement_failure": {
+				Description: "If true, the plan will fail when Nomad reports that task groups cannot be placed.",
+				Optional:    true,
+				Type:        schema.TypeBool,
+			},
+
 			"deregister_on_destroy": {
 				Description: "If true, the job will be deregistered on destroy.",
 				Optional:    true,
@@ -61,84 +84,122 @@ func resourceJob() *schema.Resource {
 				Type:        schema.TypeBool,
 			},
 
-			"deployment_id": {
-				Description: "If detach = false, the ID for the deployment associated with the last job create/update, if one exists.",
-				Computed:    true,
-				Type:        schema.TypeString,
+			"preserve_counts": {
+				Description: "If true, the count of task groups with an enabled scaling policy will not be changed when the job is updated.",
+				Optional:    true,
+				Type:        schema.TypeBool,
 			},
 
-			"deployment_status": {
-				Description: "If detach = false, the status for the deployment associated with the last job create/update, if one exists.",
+			"overrides": jobOverridesSchema(),
+
+			"detect_drift": {
+				Description: "If true, the provider will check if the job registered in Nomad differs from the jobspec on refresh.",
+				Optional:    true,
+				Type:        schema.TypeBool,
+			},
+
+			"drift": {
+				Description: "If detect_drift = true, the diff reported by Nomad between the jobspec and the job registered in Nomad, when the job was modified outside of Terraform.",
 				Computed:    true,
 				Type:        schema.TypeString,
 			},
 
-			"json": {
-				Description: "If true, the `jobspec` will be parsed as json instead of HCL.",
+			"wait_for_completion": {
+				Description: "If detach = false, for batch jobs, wait until all the allocations of the job are complete and fail if any of them failed.",
 				Optional:    true,
 				Type:        schema.TypeBool,
 			},
 
-			"modify_index": {
-				Description: "Integer that increments for each change. Used to detect any changes between plan and apply.",
-				Computed:    true,
-				Type:        schema.TypeString, // it's an int64, so won't fit in our TypeInt
+			"promote_canaries": {
+				Description: "If detach = false, whether healthy canaries are promoted by the provider (`auto`) or left for manual promotion (`manual`).",
+				Optional:    true,
+				Default:     PromoteCanariesManual,
+				Type:        schema.TypeString,
+				ValidateFunc: validation.StringInSlice([]string{
+					PromoteCanariesAuto,
+					PromoteCanariesManual,
+				}, false),
 			},
 
-			"name": {
-				Description: "The name of the job, as derived from the jobspec.",
-				Computed:    true,
-				Type:        schema.TypeString,
+			"revert_on_failure": {
+				Description: "If detach = false, whether the job is reverted to its last stable version when the deployment fails.",
+				Optional:    true,
+				Type:        schema.TypeBool,
 			},
 
-			"namespace": {
-				Description: "The namespace of the job, as derived from the jobspec.",
+			"deployment_id": {
+				Description: "If detach = false, the ID for the deployment associated with the last job create/update, if one exists.",
 				Computed:    true,
 				Type:        schema.TypeString,
 			},
 
-			"type": {
-				Description: "The type of the job, as derived from the jobspec.",
+			"deployment_status": {
+				Description: "If detach = false, the status for the deployment associated with the last job create/update, if one exists.",
 				Computed:    true,
 				Type:        schema.TypeString,
 			},
 
-			"region": {
-				Description: "The target region for the job, as derived from the jobspec.",
+			"deployment_status_description": {
+				Description: "If detach = false, the description of the status for the deployment associated with the last job create/update, if one exists.",
 				Computed:    true,
 				Type:        schema.TypeString,
 			},
 
-			"datacenters": {
-				Description: "The target datacenters for the job, as derived from the jobspec.",
+			"multiregion_deployments": {
+				Description: "If detach = false, for multiregion jobs, the deployment associated with the last job create/update in each region.",
 				Computed:    true,
-				Type:        schema.TypeSet,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
+				Type:        schema.TypeList,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"region": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"deployment_id": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"status": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"status_description": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+					},
 				},
 			},
 
-			"allocation_ids": {
-				Description: "The IDs for allocations associated with this job.",
+			"allocation_failures": {
+				Description: "If detach = false, the allocations that failed or were unhealthy when the last deployment failed.",
 				Computed:    true,
 				Type:        schema.TypeList,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
-				},
-			},
-
-			"task_groups": {
-				Computed: true,
-				Type:     schema.TypeList,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"name": {
+						"allocation_id": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"task_group": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"node_name": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"client_status": {
+							Computed: true,
+							Type:     schema.TypeString,
+						},
+						"client_description": {
 							Computed: true,
 							Type:     schema.TypeString,
 						},
-						"count": {
+						"unhealthy": {
 							Computed: true,
-							Type:     schema.TypeInt,
+							Type:     schema.TypeBool,
 						},
 						"task": {
 							Computed: true,
@@ -149,30 +210,34 @@ func resourceJob() *schema.Resource {
 										Computed: true,
 										Type:     schema.TypeString,
 									},
-									"driver": {
+									"state": {
 										Computed: true,
 										Type:     schema.TypeString,
 									},
-									"meta": {
+									"failed": {
+										Computed: true,
+										Type:     schema.TypeBool,
+									},
+									"exit_code": {
 										Computed: true,
-										Type:     schema.TypeMap,
+										Type:     schema.TypeInt,
 									},
-									"volume_mounts": {
+									"events": {
 										Computed: true,
 										Type:     schema.TypeList,
 										Elem: &schema.Resource{
 											Schema: map[string]*schema.Schema{
-												"volume": {
+												"time": {
 													Computed: true,
 													Type:     schema.TypeString,
 												},
-												"destination": {
+												"type": {
 													Computed: true,
 													Type:     schema.TypeString,
 												},
-												"read_only": {
+												"message": {
 													Computed: true,
-													Type:     schema.TypeBool,
+													Type:     schema.TypeString,
 												},
 											},
 										},
@@ -180,54 +245,157 @@ func resourceJob() *schema.Resource {
 								},
 							},
 						},
-						"volumes": {
-							Computed: true,
-							Type:     schema.TypeList,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"name": {
-										Computed: true,
-										Type:     schema.TypeString,
-									},
-									"type": {
-										Computed: true,
-										Type:     schema.TypeString,
-									},
-									"read_only": {
-										Computed: true,
-										Type:     schema.TypeBool,
-									},
-									"source": {
-										Computed: true,
-										Type:     schema.TypeString,
-									},
-								},
-							},
+					},
+				},
+			},
+
+			"plan_diff": {
+				Description: "The diff reported by Nomad when planning a change to the jobspec, until it is refreshed after the change is applied.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"plan_warnings": {
+				Description: "The warnings reported by Nomad when planning a change to the jobspec, and the updates that disrupt running allocations.",
+				Computed:    true,
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"json": {
+				Description:      "If true, the `jobspec` will be parsed as json instead of HCL.",
+				Optional:         true,
+				Default:          false,
+				Type:             schema.TypeBool,
+				DiffSuppressFunc: jobParserOptionDiffSuppress,
+			},
+
+			"hcl2": {
+				Description: "Configuration for the HCL2 jobspec parser.",
+				Optional:    true,
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Description: "If true, the `jobspec` will be parsed as HCL2 instead of HCL.",
+							Optional:    true,
+							Default:     false,
+							Type:        schema.TypeBool,
 						},
-						"meta": {
-							Computed: true,
-							Type:     schema.TypeMap,
+						"vars": {
+							Description: "Additional variables to use when templating the job with HCL2.",
+							Optional:    true,
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
 						},
 					},
 				},
 			},
 
+			"modify_index": {
+				Description: "Integer that increments for each change. Used to detect any changes between plan and apply.",
+				Computed:    true,
+				Type:        schema.TypeString, // it's an int64, so won't fit in our TypeInt
+			},
+
+			"name": {
+				Description: "The name of the job, as derived from the jobspec.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"namespace": {
+				Description: "The namespace of the job, as derived from the jobspec.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"type": {
+				Description: "The type of the job, as derived from the jobspec.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"region": {
+				Description: "The target region for the job, as derived from the jobspec.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"datacenters": {
+				Description: "The target datacenters for the job, as derived from the jobspec.",
+				Computed:    true,
+				Type:        schema.TypeSet,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"allocation_ids": {
+				Description: "The IDs for allocations associated with this job.",
+				Computed:    true,
+				Type:        schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"task_groups": jobTaskGroupsSchema(),
+
 			"purge_on_destroy": {
 				Description: "Whether to purge the job when the resource is destroyed.",
 				Optional:    true,
 				Type:        schema.TypeBool,
 			},
+
+			"destroy_mode": {
+				Description: "How the job is removed when the resource is destroyed: stop, deregister or purge. Overrides purge_on_destroy.",
+				Optional:    true,
+				Type:        schema.TypeString,
+				ValidateFunc: validation.StringInSlice([]string{
+					DestroyModeStop,
+					DestroyModeDeregister,
+					DestroyModePurge,
+				}, false),
+				ConflictsWith: []string{"purge_on_destroy"},
+			},
+
+			"wait_for_allocations_on_destroy": {
+				Description: "If true, wait until all the allocations of the job are terminal before completing the destroy.",
+				Optional:    true,
+				Type:        schema.TypeBool,
+			},
 		},
 	}
 }
 
 const (
-	MonitoringEvaluation = "monitoring_evaluation"
-	EvaluationComplete   = "evaluation_complete"
-	MonitoringDeployment = "monitoring_deployment"
-	DeploymentSuccessful = "deployment_successful"
+	MonitoringEvaluation     = "monitoring_evaluation"
+	EvaluationComplete       = "evaluation_complete"
+	MonitoringDeployment     = "monitoring_deployment"
+	DeploymentSuccessful     = "deployment_successful"
+	DeploymentNeedsPromotion = "deployment_needs_promotion"
+	MonitoringAllocations    = "monitoring_allocations"
+	AllocationsComplete      = "allocations_complete"
+)
+
+const (
+	PromoteCanariesAuto   = "auto"
+	PromoteCanariesManual = "manual"
+)
+
+const (
+	DestroyModeStop       = "stop"
+	DestroyModeDeregister = "deregister"
+	DestroyModePurge      = "purge"
 )
 
+// deploymentStatusDescriptionNeedsPromotion is the status description set by
+// Nomad when the canaries of a deployment are healthy and need to be
+// promoted.
+const deploymentStatusDescriptionNeedsPromotion = "Deployment is running but requires manual promotion"
+
 func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 	timeout := d.Timeout(schema.TimeoutCreate)
 	if !d.IsNewResource() {
@@ -239,8 +407,12 @@ func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 
 	// Get the jobspec itself
 	jobspecRaw := d.Get("jobspec").(string)
-	is_json := d.Get("json").(bool)
-	job, err := parseJobspec(jobspecRaw, is_json, providerConfig.vaultToken)
+	jobParserConfig := parseJobParserConfig(d)
+	vaultToken, consulToken, err := jobTokens(d, providerConfig)
+	if err != nil {
+		return err
+	}
+	job, err := parseJobspec(jobspecRaw, jobParserConfig, vaultToken, consulToken)
 	if err != nil {
 		return err
 	}
@@ -249,6 +421,15 @@ func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 		defaultNamespace := "default"
 		job.Namespace = &defaultNamespace
 	}
+	if err := applyJobOverrides(job, d); err != nil {
+		return err
+	}
+
+	if d.Get("preserve_counts").(bool) {
+		if err := preserveTaskGroupCounts(client, job); err != nil {
+			return fmt.Errorf("error reading current task group counts: %s", err)
+		}
+	}
 
 	// Register the job
 	wantModifyIndexStrI, _ := d.GetChange("modify_index")
@@ -257,10 +438,34 @@ func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 		wantModifyIndex = 0
 	}
 
-	resp, _, err := client.Jobs().RegisterOpts(job, &api.RegisterOptions{
+	registerOpts := &api.RegisterOptions{
 		PolicyOverride: d.Get("policy_override").(bool),
 		ModifyIndex:    wantModifyIndex,
-	}, nil)
+	}
+
+	// When requests are retried, the registration is enforced against the
+	// modify index of the job so that it is never applied twice. Updates use
+	// the modify index in the state, which was checked during the plan.
+	if providerConfig.retry.maxAttempts > 1 {
+		registerOpts.EnforceIndex = true
+		if d.IsNewResource() || d.Id() != *job.ID {
+			registerOpts.ModifyIndex, err = currentJobModifyIndex(client, job)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	resp, _, err := client.Jobs().RegisterOpts(job, registerOpts, nil)
+	if err != nil && registerOpts.EnforceIndex && strings.Contains(err.Error(), "Enforcing job modify index") {
+		// A retried registration conflicts with the one that was applied
+		// by the first attempt.
+		var registered bool
+		resp, registered, err = jobAlreadyRegistered(client, job, registerOpts.ModifyIndex, err)
+		if registered {
+			log.Printf("[DEBUG] job '%s' was already registered by a previous attempt", *job.ID)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("error applying jobspec: %s", err)
 	}
@@ -273,18 +478,74 @@ func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 
 	if d.Get("detach") == false && resp.EvalID != "" {
 		log.Printf("[DEBUG] will monitor scheduling/deployment of job '%s'", *job.ID)
-		deployment, err := monitorDeployment(client, timeout, resp.EvalID)
-		if err != nil {
-			return fmt.Errorf(
-				"error waiting for job '%s' to schedule/deploy successfully: %s",
-				*job.ID, err)
+		autoPromote := d.Get("promote_canaries").(string) == PromoteCanariesAuto
+		if job.Multiregion != nil && len(job.Multiregion.Regions) > 0 {
+			deployments, err := monitorMultiregionDeployment(client, timeout, job, autoPromote)
+			d.Set("deployment_id", nil)
+			d.Set("deployment_status", nil)
+			d.Set("deployment_status_description", nil)
+			d.Set("allocation_failures", nil)
+			d.Set("multiregion_deployments", flattenMultiregionDeployments(job, deployments))
+			if err != nil {
+				return fmt.Errorf(
+					"error waiting for job '%s' to schedule/deploy successfully: %s",
+					*job.ID, err)
+			}
+			return resourceJobRead(d, meta)
 		}
+
+		deployment, err := monitorDeployment(client, timeout, resp.EvalID, autoPromote)
+		d.Set("multiregion_deployments", nil)
 		if deployment != nil {
 			d.Set("deployment_id", deployment.ID)
 			d.Set("deployment_status", deployment.Status)
+			d.Set("deployment_status_description", deployment.StatusDescription)
 		} else {
 			d.Set("deployment_id", nil)
 			d.Set("deployment_status", nil)
+			d.Set("deployment_status_description", nil)
+		}
+		d.Set("allocation_failures", nil)
+		if err != nil {
+			if deployment != nil && deployment.Status == "failed" {
+				failed, allocsErr := deploymentFailedAllocations(client, deployment)
+				if allocsErr != nil {
+					log.Printf("[ERROR] failed to list allocations of deployment '%s': %s", deployment.ID, allocsErr)
+				} else if len(failed) > 0 {
+					d.Set("allocation_failures", flattenAllocationFailures(failed, allocationFailureEvents))
+					err = fmt.Errorf("%s\n%d allocation(s) failed:\n%s",
+						err, len(failed), formatAllocationFailures(failed, allocationFailureEvents))
+				}
+			}
+			if deployment != nil && deployment.Status == "failed" && d.Get("revert_on_failure").(bool) {
+				version, revertErr := revertJobToStableVersion(client, deployment, stringValue(consulToken), stringValue(vaultToken))
+				if revertErr != nil {
+					log.Printf("[ERROR] failed to revert job '%s': %s", *job.ID, revertErr)
+					d.Set("deployment_status_description", fmt.Sprintf(
+						"%s; failed to revert job: %s", deployment.StatusDescription, revertErr))
+				} else {
+					log.Printf("[DEBUG] job '%s' reverted to version %d", *job.ID, version)
+					d.Set("deployment_status_description", fmt.Sprintf(
+						"%s; job reverted to version %d", deployment.StatusDescription, version))
+				}
+			}
+			return fmt.Errorf(
+				"error waiting for job '%s' to schedule/deploy successfully: %s",
+				*job.ID, err)
+		}
+
+		if job.Type != nil && *job.Type == "batch" && d.Get("wait_for_completion").(bool) {
+			log.Printf("[DEBUG] will wait for completion of batch job '%s'", *job.ID)
+			registered, _, err := client.Jobs().Info(*job.ID, &api.QueryOptions{
+				Namespace: *job.Namespace,
+			})
+			if err != nil {
+				return fmt.Errorf("error reading job '%s': %s", *job.ID, err)
+			}
+			err = monitorBatchJob(client, timeout, "", *job.ID, *job.Namespace, *registered.Version)
+			if err != nil {
+				return fmt.Errorf("error waiting for job '%s' to complete: %s", *job.ID, err)
+			}
 		}
 	}
 
@@ -293,7 +554,10 @@ func resourceJobRegister(d *schema.ResourceData, meta interface{}) error {
 
 // monitorDeployment monitors the evalution(s) from a job create/update and,
 // if they result in a deployment, monitors that deployment until completion.
-func monitorDeployment(client *api.Client, timeout time.Duration, initialEvalID string) (*api.Deployment, error) {
+// If autoPromote is true, the canaries of the deployment are promoted once
+// they are healthy, otherwise monitoring stops when the deployment requires a
+// manual promotion.
+func monitorDeployment(client *api.Client, timeout time.Duration, initialEvalID string, autoPromote bool) (*api.Deployment, error) {
 
 	stateConf := &resource.StateChangeConf{
 		Pending:    []string{MonitoringEvaluation},
@@ -317,18 +581,19 @@ func monitorDeployment(client *api.Client, timeout time.Duration, initialEvalID
 
 	stateConf = &resource.StateChangeConf{
 		Pending:    []string{MonitoringDeployment},
-		Target:     []string{DeploymentSuccessful},
-		Refresh:    deploymentStateRefreshFunc(client, evaluation.DeploymentID),
+		Target:     []string{DeploymentSuccessful, DeploymentNeedsPromotion},
+		Refresh:    deploymentStateRefreshFunc(client, evaluation.DeploymentID, autoPromote),
 		Timeout:    timeout,
 		Delay:      0,
 		MinTimeout: 5 * time.Second,
 	}
 
 	state, err = stateConf.WaitForState()
+	deployment, _ := state.(*api.Deployment)
 	if err != nil {
-		return nil, fmt.Errorf("error waiting for evaluation: %s", err)
+		return deployment, fmt.Errorf("error waiting for evaluation: %s", err)
 	}
-	return state.(*api.Deployment), nil
+	return deployment, nil
 }
 
 // evaluationStateRefreshFunc returns a resource.StateRefreshFunc that is used to watch
@@ -371,7 +636,7 @@ func evaluationStateRefreshFunc(client *api.Client, initialEvalID string) resour
 
 // deploymentStateRefreshFunc returns a resource.StateRefreshFunc that is used to watch
 // the deployment from a job create/update
-func deploymentStateRefreshFunc(client *api.Client, deploymentID string) resource.StateRefreshFunc {
+func deploymentStateRefreshFunc(client *api.Client, deploymentID string, autoPromote bool) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		// monitor the deployment
 		var state string
@@ -390,40 +655,599 @@ func deploymentStateRefreshFunc(client *api.Client, deploymentID string) resourc
 				fmt.Errorf("deployment '%s' terminated with status '%s': '%s'",
 					deployment.ID, deployment.Status, deployment.StatusDescription)
 		default:
-			// don't overwhelm the API server
 			state = MonitoringDeployment
+
+			if !deploymentReadyForPromotion(deployment) {
+				break
+			}
+			if !autoPromote {
+				log.Printf("[WARN] deployment '%s' requires manual promotion", deployment.ID)
+				state = DeploymentNeedsPromotion
+				break
+			}
+
+			log.Printf("[DEBUG] promoting canaries of deployment '%s'", deployment.ID)
+			_, _, err := client.Deployments().PromoteAll(deployment.ID, &api.WriteOptions{
+				Namespace: deployment.Namespace,
+			})
+			if err != nil {
+				log.Printf("[ERROR] error on Deployment.PromoteAll during deploymentStateRefresh: %s", err)
+				return deployment, "", err
+			}
 		}
 		return deployment, state, nil
 	}
 }
 
-func resourceJobDeregister(d *schema.ResourceData, meta interface{}) error {
-	providerConfig := meta.(ProviderConfig)
-	client := providerConfig.client
-
-	// If deregistration is disabled, then do nothing
-	deregister_on_destroy := d.Get("deregister_on_destroy").(bool)
-	if !deregister_on_destroy {
-		log.Printf(
-			"[WARN] job %q will not deregister since "+
-				"'deregister_on_destroy' is %t", d.Id(), deregister_on_destroy)
-		return nil
+// deploymentReadyForPromotion returns whether the canaries of a deployment
+// can be promoted. Nomad reports that a deployment needs a promotion as soon
+// as it is created, but the promotion is rejected until the canaries of
+// every task group are placed and healthy.
+func deploymentReadyForPromotion(deployment *api.Deployment) bool {
+	if deployment.Status != deploymentStatusRunning ||
+		deployment.StatusDescription != deploymentStatusDescriptionNeedsPromotion {
+		return false
+	}
+	for tg, state := range deployment.TaskGroups {
+		if state.DesiredCanaries == 0 || state.Promoted {
+			continue
+		}
+		if state.HealthyAllocs < state.DesiredCanaries {
+			log.Printf("[DEBUG] %d of %d canaries of task group %q healthy in deployment '%s'",
+				state.HealthyAllocs, state.DesiredCanaries, tg, deployment.ID)
+			return false
+		}
+	}
+	return true
+}
+
+// monitorBatchJob waits until all the allocations of the given version of a
+// batch job are terminal, and returns an error describing the failed
+// allocations if any of them failed. If evalID is set, the evaluation and
+// its follow-up evaluations are waited for first, as the job has no
+// allocation until they are processed by the scheduler.
+func monitorBatchJob(client *api.Client, timeout time.Duration, evalID, jobID, namespace string, version uint64) error {
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{MonitoringAllocations},
+		Target:     []string{AllocationsComplete},
+		Refresh:    batchJobStateRefreshFunc(client, evalID, jobID, namespace, version),
+		Timeout:    timeout,
+		Delay:      0,
+		MinTimeout: 3 * time.Second,
+	}
+
+	_, err := stateConf.WaitForState()
+	return err
+}
+
+// batchJobStateRefreshFunc returns a resource.StateRefreshFunc that is used to
+// watch the allocations of a batch job until they are all terminal. If
+// evalID is set, the allocations are only watched once the evaluation is
+// complete.
+func batchJobStateRefreshFunc(client *api.Client, evalID, jobID, namespace string, version uint64) resource.StateRefreshFunc {
+	q := &api.QueryOptions{Namespace: namespace}
+
+	var evalRefresh resource.StateRefreshFunc
+	if evalID != "" {
+		evalRefresh = evaluationStateRefreshFunc(client, evalID)
+	}
+
+	return func() (interface{}, string, error) {
+		if evalRefresh != nil {
+			eval, state, err := evalRefresh()
+			if err != nil {
+				return nil, "", err
+			}
+			if state != EvaluationComplete {
+				return eval, MonitoringAllocations, nil
+			}
+			evalRefresh = nil
+		}
+
+		summary, _, err := client.Jobs().Summary(jobID, q)
+		if err != nil {
+			log.Printf("[ERROR] error on Jobs.Summary during batchJobStateRefresh: %s", err)
+			return nil, "", err
+		}
+		for tg, s := range summary.Summary {
+			if s.Queued > 0 || s.Starting > 0 {
+				log.Printf("[DEBUG] task group %q of job '%s' has allocations queued or starting", tg, jobID)
+				return summary, MonitoringAllocations, nil
+			}
+		}
+
+		allocs, _, err := client.Jobs().Allocations(jobID, false, q)
+		if err != nil {
+			log.Printf("[ERROR] error on Jobs.Allocations during batchJobStateRefresh: %s", err)
+			return nil, "", err
+		}
+
+		// Allocations that have been rescheduled are replaced by a new
+		// allocation, only the latter matters.
+		replaced := make(map[string]bool)
+		for _, a := range allocs {
+			if a.RescheduleTracker == nil {
+				continue
+			}
+			for _, e := range a.RescheduleTracker.Events {
+				replaced[e.PrevAllocID] = true
+			}
+		}
+
+		var failed []*api.AllocationListStub
+		for _, a := range allocs {
+			if a.JobVersion < version || replaced[a.ID] {
+				continue
+			}
+			switch a.ClientStatus {
+			case "complete":
+			case "failed", "lost":
+				if a.FollowupEvalID != "" {
+					// the allocation will be rescheduled
+					return allocs, MonitoringAllocations, nil
+				}
+				failed = append(failed, a)
+			default:
+				return allocs, MonitoringAllocations, nil
+			}
+		}
+
+		if len(failed) > 0 {
+			return allocs, "", fmt.Errorf("%d allocation(s) failed:\n%s",
+				len(failed), formatAllocationFailures(failed, allocationFailureEvents))
+		}
+
+		log.Printf("[DEBUG] all allocations of job '%s' are complete", jobID)
+		return allocs, AllocationsComplete, nil
+	}
+}
+
+// allocationFailureEvents is the number of task events reported for each
+// task of a failed allocation.
+const allocationFailureEvents = 5
+
+// formatAllocationFailures renders the state of the tasks of failed
+// allocations, with their exit code and their last task events.
+func formatAllocationFailures(allocs []*api.AllocationListStub, maxEvents int) string {
+	var b strings.Builder
+	for _, a := range sortedAllocations(allocs) {
+		fmt.Fprintf(&b, "allocation %q (task group %q, node %q) is %s",
+			shortID(a.ID), a.TaskGroup, a.NodeName, a.ClientStatus)
+		if allocationIsUnhealthy(a) {
+			b.WriteString(" and unhealthy")
+		}
+		if a.ClientDescription != "" {
+			fmt.Fprintf(&b, ": %s", a.ClientDescription)
+		}
+		b.WriteString("\n")
+
+		for _, name := range sortedTaskNames(a.TaskStates) {
+			state := a.TaskStates[name]
+
+			fmt.Fprintf(&b, "  task %q is %s", name, state.State)
+			if state.Failed {
+				b.WriteString(" (failed)")
+			}
+			if code, ok := taskExitCode(state); ok {
+				fmt.Fprintf(&b, ", exit code %d", code)
+			}
+			b.WriteString("\n")
+
+			for _, e := range lastTaskEvents(state, maxEvents) {
+				fmt.Fprintf(&b, "    * %s %s: %s\n",
+					formatTaskEventTime(e), e.Type, taskEventMessage(e))
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// flattenAllocationFailures returns the same information as
+// formatAllocationFailures, in the format of the allocation_failures
+// attribute.
+func flattenAllocationFailures(allocs []*api.AllocationListStub, maxEvents int) []interface{} {
+	result := make([]interface{}, 0, len(allocs))
+	for _, a := range sortedAllocations(allocs) {
+		tasks := make([]interface{}, 0, len(a.TaskStates))
+		for _, name := range sortedTaskNames(a.TaskStates) {
+			state := a.TaskStates[name]
+
+			events := make([]interface{}, 0, maxEvents)
+			for _, e := range lastTaskEvents(state, maxEvents) {
+				events = append(events, map[string]interface{}{
+					"time":    formatTaskEventTime(e),
+					"type":    e.Type,
+					"message": taskEventMessage(e),
+				})
+			}
+
+			task := map[string]interface{}{
+				"name":   name,
+				"state":  state.State,
+				"failed": state.Failed,
+				"events": events,
+			}
+			if code, ok := taskExitCode(state); ok {
+				task["exit_code"] = code
+			}
+			tasks = append(tasks, task)
+		}
+
+		result = append(result, map[string]interface{}{
+			"allocation_id":      a.ID,
+			"task_group":         a.TaskGroup,
+			"node_name":          a.NodeName,
+			"client_status":      a.ClientStatus,
+			"client_description": a.ClientDescription,
+			"unhealthy":          allocationIsUnhealthy(a),
+			"task":               tasks,
+		})
+	}
+	return result
+}
+
+// deploymentFailedAllocations returns the allocations of a deployment that
+// failed or were marked as unhealthy.
+func deploymentFailedAllocations(client *api.Client, deployment *api.Deployment) ([]*api.AllocationListStub, error) {
+	allocs, _, err := client.Deployments().Allocations(deployment.ID, &api.QueryOptions{
+		Namespace: deployment.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*api.AllocationListStub
+	for _, a := range allocs {
+		if a.ClientStatus == "failed" || a.ClientStatus == "lost" || allocationIsUnhealthy(a) {
+			failed = append(failed, a)
+		}
+	}
+	return failed, nil
+}
+
+// allocationIsUnhealthy returns whether the allocation has been marked as
+// unhealthy by its deployment.
+func allocationIsUnhealthy(a *api.AllocationListStub) bool {
+	return a.DeploymentStatus != nil && a.DeploymentStatus.Healthy != nil && !*a.DeploymentStatus.Healthy
+}
+
+func sortedAllocations(allocs []*api.AllocationListStub) []*api.AllocationListStub {
+	sorted := make([]*api.AllocationListStub, len(allocs))
+	copy(sorted, allocs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+func sortedTaskNames(states map[string]*api.TaskState) []string {
+	names := make([]string, 0, len(states))
+	for name, state := range states {
+		if state != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lastTaskEvents returns the last maxEvents events of a task.
+func lastTaskEvents(state *api.TaskState, maxEvents int) []*api.TaskEvent {
+	events := state.Events
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	return events
+}
+
+func taskEventMessage(e *api.TaskEvent) string {
+	if e.DisplayMessage != "" {
+		return e.DisplayMessage
+	}
+	return e.Message
+}
+
+func formatTaskEventTime(e *api.TaskEvent) string {
+	return time.Unix(0, e.Time).UTC().Format(time.RFC3339)
+}
+
+// taskExitCode returns the exit code of the last time the task terminated.
+func taskExitCode(state *api.TaskState) (int, bool) {
+	for i := len(state.Events) - 1; i >= 0; i-- {
+		e := state.Events[i]
+		if e.Type != api.TaskTerminated {
+			continue
+		}
+		if code, ok := e.Details["exit_code"]; ok {
+			if c, err := strconv.Atoi(code); err == nil {
+				return c, true
+			}
+		}
+		return e.ExitCode, true
+	}
+	return 0, false
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// revertJobToStableVersion reverts the job of a failed deployment to its
+// latest stable version, and returns this version. The revert only happens if
+// the job is still at the version of the deployment, so a job that was
+// already reverted by Nomad is left untouched.
+func revertJobToStableVersion(client *api.Client, deployment *api.Deployment, consulToken, vaultToken string) (uint64, error) {
+	versions, _, _, err := client.Jobs().Versions(deployment.JobID, false, &api.QueryOptions{
+		Namespace: deployment.Namespace,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error listing job versions: %s", err)
+	}
+
+	var stable *api.Job
+	for _, v := range versions {
+		if v.Version == nil || v.Stable == nil || !*v.Stable || *v.Version >= deployment.JobVersion {
+			continue
+		}
+		if stable == nil || *v.Version > *stable.Version {
+			stable = v
+		}
+	}
+	if stable == nil {
+		return 0, fmt.Errorf("no stable version prior to version %d", deployment.JobVersion)
+	}
+
+	enforcePriorVersion := deployment.JobVersion
+	_, err = revertJob(client, deployment.JobID, *stable.Version, &enforcePriorVersion, &api.WriteOptions{
+		Namespace: deployment.Namespace,
+	}, consulToken, vaultToken)
+	if err != nil {
+		return 0, err
+	}
+
+	return *stable.Version, nil
+}
+
+// revertJob reverts a job to the given version. It is the same as
+// Jobs().Revert, which does not send the Consul token yet.
+func revertJob(client *api.Client, jobID string, version uint64, enforcePriorVersion *uint64,
+	q *api.WriteOptions, consulToken, vaultToken string) (*api.JobRegisterResponse, error) {
+
+	var resp api.JobRegisterResponse
+	req := &api.JobRevertRequest{
+		JobID:               jobID,
+		JobVersion:          version,
+		EnforcePriorVersion: enforcePriorVersion,
+		ConsulToken:         consulToken,
+		VaultToken:          vaultToken,
+	}
+	if _, err := client.Raw().Write("/v1/job/"+url.PathEscape(jobID)+"/revert", req, &resp, q); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func resourceJobDeregister(d *schema.ResourceData, meta interface{}) error {
+	providerConfig := meta.(ProviderConfig)
+	client := providerConfig.client
+
+	// If deregistration is disabled, then do nothing
+	deregister_on_destroy := d.Get("deregister_on_destroy").(bool)
+	if !deregister_on_destroy {
+		log.Printf(
+			"[WARN] job %q will not deregister since "+
+				"'deregister_on_destroy' is %t", d.Id(), deregister_on_destroy)
+		return nil
+	}
+
+	id := d.Id()
+	namespace := d.Get("namespace").(string)
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	// The allocations are listed before the job is stopped since they may
+	// not be reachable through the job anymore once it has been purged.
+	var allocIDs []string
+	wait := d.Get("wait_for_allocations_on_destroy").(bool)
+	if wait {
+		allocs, _, err := client.Jobs().Allocations(id, false, &api.QueryOptions{Namespace: namespace})
+		if err != nil && !strings.Contains(err.Error(), "404") {
+			return fmt.Errorf("error listing allocations of job: %s", err)
+		}
+		for _, a := range allocs {
+			if !allocationIsTerminal(a.ClientStatus) {
+				allocIDs = append(allocIDs, a.ID)
+			}
+		}
 	}
 
-	id := d.Id()
-	log.Printf("[DEBUG] deregistering job: %q", id)
 	opts := &api.WriteOptions{
-		Namespace: d.Get("namespace").(string),
+		Namespace: namespace,
 	}
-	if opts.Namespace == "" {
-		opts.Namespace = "default"
+
+	mode := jobDestroyMode(d)
+	log.Printf("[DEBUG] deregistering job: %q (destroy mode %q)", id, mode)
+	switch mode {
+	case DestroyModeStop:
+		vaultToken, consulToken, err := jobTokens(d, providerConfig)
+		if err != nil {
+			return err
+		}
+		if err := stopJob(client, id, vaultToken, consulToken, opts); err != nil {
+			return err
+		}
+	default:
+		purge := mode == DestroyModePurge
+		_, _, err := client.Jobs().Deregister(id, purge, opts)
+		if err != nil {
+			// A purge that timed out after Nomad applied it is retried,
+			// and the retry does not find the job anymore.
+			if !purge || !strings.Contains(err.Error(), "404") {
+				return fmt.Errorf("error deregistering job: %s", err)
+			}
+			log.Printf("[WARN] job %q not found, it is already purged", id)
+		}
 	}
-	purge := d.Get("purge_on_destroy").(bool)
-	_, _, err := client.Jobs().Deregister(id, purge, opts)
+
+	if wait && len(allocIDs) > 0 {
+		log.Printf("[DEBUG] waiting for %d allocation(s) of job %q to stop", len(allocIDs), id)
+		stateConf := &resource.StateChangeConf{
+			Pending:    []string{MonitoringAllocations},
+			Target:     []string{AllocationsComplete},
+			Refresh:    allocationsStoppedRefreshFunc(client, allocIDs, namespace),
+			Timeout:    d.Timeout(schema.TimeoutDelete),
+			Delay:      0,
+			MinTimeout: 3 * time.Second,
+		}
+		if _, err := stateConf.WaitForState(); err != nil {
+			return fmt.Errorf("error waiting for allocations of job to stop: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// jobDestroyMode returns the destroy mode of the job, falling back to the
+// legacy purge_on_destroy flag when destroy_mode is not set.
+func jobDestroyMode(d resourceFieldGetter) string {
+	if mode, ok := d.Get("destroy_mode").(string); ok && mode != "" {
+		return mode
+	}
+	if purge, ok := d.Get("purge_on_destroy").(bool); ok && purge {
+		return DestroyModePurge
+	}
+	return DestroyModeDeregister
+}
+
+// currentJobModifyIndex returns the modify index of the registered version
+// of a job, or 0 if the job does not exist.
+func currentJobModifyIndex(client *api.Client, job *api.Job) (uint64, error) {
+	current, _, err := client.Jobs().Info(*job.ID, &api.QueryOptions{Namespace: *job.Namespace})
 	if err != nil {
-		return fmt.Errorf("error deregistering job: %s", err)
+		if strings.Contains(err.Error(), "404") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading job: %s", err)
+	}
+	if current.JobModifyIndex == nil {
+		return 0, nil
+	}
+	return *current.JobModifyIndex, nil
+}
+
+// jobAlreadyRegistered returns whether a registration that failed to
+// enforce the modify index of the job was already applied, that is whether
+// the job was modified since that index and is now the same as the one to
+// register. registerErr is returned as is otherwise.
+func jobAlreadyRegistered(client *api.Client, job *api.Job, modifyIndex uint64, registerErr error) (*api.JobRegisterResponse, bool, error) {
+	current, _, err := client.Jobs().Info(*job.ID, &api.QueryOptions{Namespace: *job.Namespace})
+	if err != nil || current.JobModifyIndex == nil || *current.JobModifyIndex <= modifyIndex {
+		return nil, false, registerErr
 	}
 
+	want, err := normalizeJobForDiff(job, false)
+	if err != nil {
+		return nil, false, registerErr
+	}
+	got, err := normalizeJobForDiff(current, false)
+	if err != nil || !reflect.DeepEqual(want, got) {
+		return nil, false, registerErr
+	}
+
+	resp := &api.JobRegisterResponse{JobModifyIndex: *current.JobModifyIndex}
+	evals, _, err := client.Jobs().Evaluations(*job.ID, &api.QueryOptions{Namespace: *job.Namespace})
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading evaluations of job: %s", err)
+	}
+	for _, eval := range evals {
+		if eval.JobModifyIndex == resp.JobModifyIndex {
+			resp.EvalID = eval.ID
+			break
+		}
+	}
+	return resp, true, nil
+}
+
+// stopJob stops a job by registering its current version with Stop set, so
+// it stays registered as a stopped job and can be started again. The
+// registration is enforced against the modify index of the job to not
+// override a concurrent update.
+func stopJob(client *api.Client, jobID string, vaultToken, consulToken *string, opts *api.WriteOptions) error {
+	job, _, err := client.Jobs().Info(jobID, &api.QueryOptions{Namespace: opts.Namespace})
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			log.Printf("[DEBUG] job %q does not exist, nothing to stop", jobID)
+			return nil
+		}
+		return fmt.Errorf("error reading job: %s", err)
+	}
+	if job.Stop != nil && *job.Stop {
+		return nil
+	}
+
+	stop := true
+	job.Stop = &stop
+	job.VaultToken = vaultToken
+	job.ConsulToken = consulToken
+	_, _, err = client.Jobs().RegisterOpts(job, &api.RegisterOptions{
+		EnforceIndex: true,
+		ModifyIndex:  *job.JobModifyIndex,
+	}, opts)
+	if err != nil {
+		return fmt.Errorf("error stopping job: %s", err)
+	}
+	return nil
+}
+
+// allocationIsTerminal returns whether an allocation with the given client
+// status is done running.
+func allocationIsTerminal(clientStatus string) bool {
+	switch clientStatus {
+	case "complete", "failed", "lost":
+		return true
+	default:
+		return false
+	}
+}
+
+// allocationsStoppedRefreshFunc returns a resource.StateRefreshFunc that is
+// used to watch a set of allocations until they are all terminal. Tasks are
+// given their kill_timeout to stop by the Nomad clients.
+func allocationsStoppedRefreshFunc(client *api.Client, allocIDs []string, namespace string) resource.StateRefreshFunc {
+	q := &api.QueryOptions{Namespace: namespace}
+
+	return func() (interface{}, string, error) {
+		for _, id := range allocIDs {
+			alloc, _, err := client.Allocations().Info(id, q)
+			if err != nil {
+				if strings.Contains(err.Error(), "404") {
+					// the allocation has been garbage collected
+					continue
+				}
+				log.Printf("[ERROR] error on Allocations.Info during allocationsStoppedRefresh: %s", err)
+				return nil, "", err
+			}
+			if !allocationIsTerminal(alloc.ClientStatus) {
+				log.Printf("[DEBUG] allocation %q is %s", shortID(id), alloc.ClientStatus)
+				return alloc, MonitoringAllocations, nil
+			}
+		}
+
+		return allocIDs, AllocationsComplete, nil
+	}
+}
+
+// resourceJobRefresh reads the job and clears the attributes describing the
+// last plan, which only apply until the planned change is applied.
+func resourceJobRefresh(d *schema.ResourceData, meta interface{}) error {
+	if err := resourceJobRead(d, meta); err != nil {
+		return err
+	}
+	d.Set("plan_diff", "")
+	d.Set("plan_warnings", nil)
 	return nil
 }
 
@@ -462,6 +1286,14 @@ func resourceJobRead(d *schema.ResourceData, meta interface{}) error {
 		allocIDs = append(allocIDs, a.ID)
 	}
 
+	drift := ""
+	if d.Get("detect_drift").(bool) {
+		if drift, err = resourceJobDetectDrift(d, providerConfig, job); err != nil {
+			log.Printf("[WARN] failed to detect drift for job %q: %s", id, err)
+		}
+	}
+	d.Set("drift", drift)
+
 	d.Set("name", job.ID)
 	d.Set("type", job.Type)
 	d.Set("region", job.Region)
@@ -478,34 +1310,173 @@ func resourceJobRead(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
+// resourceJobDetectDrift plans the jobspec stored in the state against the
+// job registered in Nomad. If Nomad reports a difference, meaning that the
+// job has been modified outside of Terraform, it returns the diff, which is
+// stored in the drift attribute so that the next plan registers the job
+// again.
+func resourceJobDetectDrift(d *schema.ResourceData, providerConfig ProviderConfig, live *api.Job) (string, error) {
+	client := providerConfig.client
+
+	jobspecRaw := d.Get("jobspec").(string)
+	if jobspecRaw == "" {
+		return "", nil
+	}
+
+	vaultToken, consulToken, err := jobTokens(d, providerConfig)
+	if err != nil {
+		return "", err
+	}
+	job, err := parseJobspec(jobspecRaw, parseJobParserConfig(d), vaultToken, consulToken)
+	if err != nil {
+		return "", err
+	}
+	if job.Namespace == nil || *job.Namespace == "" {
+		defaultNamespace := "default"
+		job.Namespace = &defaultNamespace
+	}
+	if err := applyJobOverrides(job, d); err != nil {
+		return "", err
+	}
+	if d.Get("preserve_counts").(bool) {
+		applyTaskGroupCounts(job, live)
+	}
+
+	resp, _, err := client.Jobs().PlanOpts(job, &api.PlanOptions{
+		Diff:           true,
+		PolicyOverride: d.Get("policy_override").(bool),
+	}, nil)
+	if err != nil {
+		// Planning requires submit-job, which the token used to refresh
+		// may not have
+		if strings.Contains(err.Error(), "403") {
+			log.Printf("[WARN] not allowed to plan job %q, skipping drift detection: %s", *live.ID, err)
+			return "", nil
+		}
+		return "", err
+	}
+	if resp.Diff == nil || resp.Diff.Type == diffTypeNone {
+		return "", nil
+	}
+
+	drift := formatJobPlan(resp)
+	log.Printf("[DEBUG] job %q has been modified outside of Terraform:\n%s", *live.ID, drift)
+	return drift, nil
+}
+
+// resourceJobImportState imports a job registered in Nomad. The import ID is
+// either `<namespace>/<job_id>` or `<job_id>` for jobs in the default
+// namespace. Since the original jobspec is not available, a JSON jobspec is
+// reconstructed from the registered job.
+func resourceJobImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	providerConfig := meta.(ProviderConfig)
+	client := providerConfig.client
+
+	namespace, id := "default", d.Id()
+	if parts := strings.SplitN(d.Id(), "/", 2); len(parts) == 2 {
+		namespace, id = parts[0], parts[1]
+	}
+
+	log.Printf("[DEBUG] importing job %q in namespace %q", id, namespace)
+	job, _, err := client.Jobs().Info(id, &api.QueryOptions{
+		Namespace: namespace,
+	})
+
+	// The IDs of the child jobs of periodic and parameterized jobs contain
+	// a `/`, like `batch/periodic-1600000000`. When the prefix is not the
+	// namespace of the job, the ID is a job ID of the default namespace.
+	if err != nil && namespace != "default" && strings.Contains(err.Error(), "404") {
+		log.Printf("[DEBUG] job %q not found in namespace %q, importing job %q in namespace \"default\"", id, namespace, d.Id())
+		namespace, id = "default", d.Id()
+		job, _, err = client.Jobs().Info(id, &api.QueryOptions{
+			Namespace: namespace,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading job %q in namespace %q: %s", id, namespace, err)
+	}
+
+	jobspecJSON, err := jobspecFromJob(job)
+	if err != nil {
+		return nil, fmt.Errorf("error generating jobspec for job %q: %s", id, err)
+	}
+
+	d.SetId(*job.ID)
+	d.Set("namespace", job.Namespace)
+	d.Set("jobspec", jobspecJSON)
+	d.Set("json", true)
+
+	// Set the arguments that have a default value, to avoid a diff on the
+	// next plan.
+	d.Set("deregister_on_destroy", true)
+	d.Set("deregister_on_id_change", true)
+	d.Set("detach", true)
+	d.Set("promote_canaries", PromoteCanariesManual)
+
+	// Set the computed lists that are only set on apply, to avoid showing
+	// them as unknown on the next plan.
+	d.Set("allocation_failures", nil)
+	d.Set("multiregion_deployments", nil)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// jobspecFromJob returns a JSON jobspec, with the same format as the output
+// of `nomad job inspect`, for a job registered in Nomad. The fields set by
+// the server are removed.
+func jobspecFromJob(job *api.Job) (string, error) {
+	jobspecJSON, err := json.MarshalIndent(map[string]*api.Job{"Job": jobWithoutServerFields(job)}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jobspecJSON), nil
+}
+
+// jobWithoutServerFields returns a shallow copy of job without the fields
+// set by the server and the tokens used to register it.
+func jobWithoutServerFields(job *api.Job) *api.Job {
+	j := *job
+	j.Status = nil
+	j.StatusDescription = nil
+	j.Stable = nil
+	j.Version = nil
+	j.SubmitTime = nil
+	j.CreateIndex = nil
+	j.ModifyIndex = nil
+	j.JobModifyIndex = nil
+	j.VaultToken = nil
+	j.ConsulToken = nil
+	j.NomadTokenID = nil
+	return &j
+}
+
 func resourceJobCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
 	log.Printf("[DEBUG] resourceJobCustomizeDiff")
 	providerConfig := meta.(ProviderConfig)
 	client := providerConfig.client
 
-	if !d.NewValueKnown("jobspec") {
-		d.SetNewComputed("name")
-		d.SetNewComputed("modify_index")
-		d.SetNewComputed("namespace")
-		d.SetNewComputed("type")
-		d.SetNewComputed("region")
-		d.SetNewComputed("datacenters")
-		d.SetNewComputed("allocation_ids")
-		d.SetNewComputed("task_groups")
-		d.SetNewComputed("deployment_id")
-		d.SetNewComputed("deployment_status")
+	if !d.NewValueKnown("jobspec") || !d.NewValueKnown("hcl2") || !d.NewValueKnown("overrides") {
+		resourceJobSetNewComputed(d)
 		return nil
 	}
 
-	oldSpecRaw, newSpecRaw := d.GetChange("jobspec")
+	_, newSpecRaw := d.GetChange("jobspec")
 
-	if oldSpecRaw.(string) == newSpecRaw.(string) {
+	// A job modified outside of Terraform is registered again even if its
+	// configuration did not change.
+	drifted := d.Get("drift").(string) != ""
+
+	// The jobspec is compared through the diff, as HasChange reports the
+	// changes suppressed by jobspecDiffSuppress.
+	specChanged := len(d.GetChangedKeysPrefix("jobspec")) > 0 || len(d.GetChangedKeysPrefix("json")) > 0
+
+	if !specChanged && !d.HasChange("hcl2") && !d.HasChange("overrides") && !drifted {
 		// nothing to do!
 		return nil
 	}
 
-	is_json := d.Get("json").(bool)
-	job, err := parseJobspec(newSpecRaw.(string), is_json, providerConfig.vaultToken) // catch syntax errors client-side during plan
+	jobParserConfig := parseJobParserConfig(d)
+	job, err := parseJobspec(newSpecRaw.(string), jobParserConfig, nil, nil) // catch syntax errors client-side during plan
 	if err != nil {
 		return err
 	}
@@ -514,15 +1485,56 @@ func resourceJobCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
 	if job.Namespace == nil || *job.Namespace == "" {
 		job.Namespace = &defaultNamespace
 	}
+	if err := applyJobOverrides(job, d); err != nil {
+		return err
+	}
+
+	// The job cannot be planned until the cluster it targets is known
+	if providerConfig.addressUnknown {
+		log.Printf("[DEBUG] address of the provider unknown, not planning job %q", *job.ID)
+		resourceJobSetNewComputed(d)
+		return nil
+	}
+
+	job.VaultToken, job.ConsulToken, err = jobTokens(d, providerConfig)
+	if err != nil {
+		return err
+	}
+
+	if d.Get("preserve_counts").(bool) {
+		if err := preserveTaskGroupCounts(client, job); err != nil {
+			log.Printf("[WARN] failed to read current task group counts: %s", err)
+		}
+	}
 
 	resp, _, err := client.Jobs().PlanOpts(job, &api.PlanOptions{
-		Diff:           false,
+		Diff:           true,
 		PolicyOverride: d.Get("policy_override").(bool),
 	}, nil)
 	if err != nil {
 		log.Printf("[WARN] failed to validate Nomad plan: %s", err)
 	}
 
+	// Expose the Nomad diff so the Terraform plan shows what will happen to
+	// the running allocations, and not only that the jobspec changed.
+	warnings := jobPlanWarnings(resp)
+	for _, w := range warnings {
+		log.Printf("[WARN] job %q plan: %s", *job.ID, w)
+	}
+	d.SetNew("plan_diff", formatJobPlan(resp))
+	d.SetNew("plan_warnings", warnings)
+	if drifted {
+		d.SetNew("drift", "")
+	}
+
+	if resp != nil && len(resp.FailedTGAllocs) > 0 {
+		failures := formatPlacementFailures(resp.FailedTGAllocs)
+		if d.Get("fail_on_placement_failure").(bool) {
+			return fmt.Errorf("job %q has placement failures:\n%s", *job.ID, failures)
+		}
+		log.Printf("[WARN] job %q has placement failures:\n%s", *job.ID, failures)
+	}
+
 	// If we were able to successfully plan then we can safely populate our
 	// diff with new values based on the job object we got from parsing,
 	// causing the Terraform diff to correctly reflect the planned changes
@@ -575,19 +1587,159 @@ func resourceJobCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
 	// similarly, we won't know the allocation ids until after the job registration eval
 	d.SetNewComputed("allocation_ids")
 
+	// The task groups read from Nomad have their default values set, so
+	// they are set as well in the diff to not report them as changes.
+	job.Canonicalize()
 	d.SetNew("task_groups", jobTaskGroupsRaw(job.TaskGroups))
 
 	return nil
 }
 
-func parseJobspec(raw string, is_json bool, vaultToken *string) (*api.Job, error) {
+// resourceJobSetNewComputed marks the attributes that are computed from the
+// job as unknown in the diff.
+func resourceJobSetNewComputed(d *schema.ResourceDiff) {
+	d.SetNewComputed("name")
+	d.SetNewComputed("modify_index")
+	d.SetNewComputed("namespace")
+	d.SetNewComputed("type")
+	d.SetNewComputed("region")
+	d.SetNewComputed("datacenters")
+	d.SetNewComputed("allocation_ids")
+	d.SetNewComputed("task_groups")
+	d.SetNewComputed("deployment_id")
+	d.SetNewComputed("deployment_status")
+	d.SetNewComputed("deployment_status_description")
+	d.SetNewComputed("allocation_failures")
+	d.SetNewComputed("multiregion_deployments")
+	d.SetNewComputed("plan_diff")
+	d.SetNewComputed("plan_warnings")
+	d.SetNewComputed("drift")
+}
+
+// preserveTaskGroupCounts sets the count of the task groups of job that have
+// an enabled scaling policy to their current count in Nomad, so that the
+// changes made by the Nomad Autoscaler are not reverted.
+func preserveTaskGroupCounts(client *api.Client, job *api.Job) error {
+	live, _, err := client.Jobs().Info(*job.ID, &api.QueryOptions{
+		Namespace: *job.Namespace,
+	})
+	if err != nil {
+		// The job is not registered yet, so there is nothing to preserve.
+		if strings.Contains(err.Error(), "404") {
+			return nil
+		}
+		return err
+	}
+
+	applyTaskGroupCounts(job, live)
+	return nil
+}
+
+// applyTaskGroupCounts copies the count of the task groups of live to the
+// task groups of job that have an enabled scaling policy.
+func applyTaskGroupCounts(job, live *api.Job) {
+	liveCounts := make(map[string]int, len(live.TaskGroups))
+	for _, tg := range live.TaskGroups {
+		if tg.Name != nil && tg.Count != nil {
+			liveCounts[*tg.Name] = *tg.Count
+		}
+	}
+
+	for _, tg := range job.TaskGroups {
+		if tg.Name == nil || tg.Scaling == nil {
+			continue
+		}
+		if tg.Scaling.Enabled != nil && !*tg.Scaling.Enabled {
+			continue
+		}
+		if count, ok := liveCounts[*tg.Name]; ok {
+			log.Printf("[DEBUG] preserving count %d of task group %q", count, *tg.Name)
+			tg.Count = &count
+		}
+	}
+}
+
+// JobParserConfig stores the options used to parse a jobspec.
+type JobParserConfig struct {
+	JSON bool
+	HCL2 HCL2JobParserConfig
+}
+
+// HCL2JobParserConfig stores the options of the HCL2 jobspec parser.
+type HCL2JobParserConfig struct {
+	Enabled bool
+	Vars    map[string]string
+}
+
+// resourceFieldGetter is implemented by both schema.ResourceData and
+// schema.ResourceDiff.
+type resourceFieldGetter interface {
+	Get(string) interface{}
+}
+
+func parseJobParserConfig(d resourceFieldGetter) JobParserConfig {
+	return jobParserConfigFromValues(d.Get("json"), d.Get("hcl2"))
+}
+
+// jobParserConfigFromValues returns the parser options from the values of
+// the json and hcl2 arguments.
+func jobParserConfigFromValues(jsonRaw, hcl2Raw interface{}) JobParserConfig {
+	isJSON, _ := jsonRaw.(bool)
+	config := JobParserConfig{
+		JSON: isJSON,
+	}
+
+	hcl2List, _ := hcl2Raw.([]interface{})
+	if len(hcl2List) == 0 || hcl2List[0] == nil {
+		return config
+	}
+	hcl2 := hcl2List[0].(map[string]interface{})
+
+	config.HCL2.Enabled = hcl2["enabled"].(bool)
+	config.HCL2.Vars = make(map[string]string)
+	for k, v := range hcl2["vars"].(map[string]interface{}) {
+		config.HCL2.Vars[k] = v.(string)
+	}
+
+	return config
+}
+
+// jobTokens returns the Vault and Consul tokens the job is submitted with.
+// The tokens set on the resource take precedence over the ones of the
+// provider.
+func jobTokens(d resourceFieldGetter, providerConfig ProviderConfig) (*string, *string, error) {
+	var vaultToken *string
+	if token, ok := d.Get("vault_token").(string); ok && token != "" {
+		vaultToken = &token
+	} else if providerConfig.vaultToken != nil {
+		token, err := providerConfig.vaultToken.Token()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting Vault token: %s", err)
+		}
+		vaultToken = &token
+	}
+
+	consulToken := providerConfig.consulToken
+	if token, ok := d.Get("consul_token").(string); ok && token != "" {
+		consulToken = &token
+	}
+
+	return vaultToken, consulToken, nil
+}
+
+func parseJobspec(raw string, config JobParserConfig, vaultToken, consulToken *string) (*api.Job, error) {
 	var job *api.Job
 	var err error
 
-	if is_json {
+	switch {
+	case config.JSON && config.HCL2.Enabled:
+		err = fmt.Errorf("`json` and `hcl2` cannot be enabled at the same time")
+	case config.JSON:
 		job, err = parseJSONJobspec(raw)
-	} else {
-		job, err = jobspec.Parse(strings.NewReader(raw))
+	case config.HCL2.Enabled:
+		job, err = parseHCL2Jobspec(raw, config.HCL2.Vars)
+	default:
+		job, err = parseHCLJobspec(raw)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("error parsing jobspec: %s", err)
@@ -598,8 +1750,9 @@ func parseJobspec(raw string, is_json bool, vaultToken *string) (*api.Job, error
 		return nil, fmt.Errorf("error parsing jobspec: input JSON is not a valid Nomad jobspec")
 	}
 
-	// Inject the Vault token
+	// Inject the Vault and Consul tokens
 	job.VaultToken = vaultToken
+	job.ConsulToken = consulToken
 
 	return job, nil
 }
@@ -611,119 +1764,186 @@ func parseJSONJobspec(raw string) (*api.Job, error) {
 
 	err := json.Unmarshal([]byte(raw), &root)
 	if err != nil {
-		return nil, err
+		return nil, jsonJobspecDiagnostic(raw, err)
 	}
 
-	jobBytes, ok := root["Job"]
-	if !ok {
+	// Parse actual job. The whole input is decoded in both cases so that
+	// the offsets of the errors are relative to the input.
+	var job api.Job
+	if _, ok := root["Job"]; ok {
+		err = json.Unmarshal([]byte(raw), &struct{ Job *api.Job }{Job: &job})
+	} else {
 		// Parse the input as is if there's no "Job" root.
-		jobBytes = []byte(raw)
+		err = json.Unmarshal([]byte(raw), &job)
 	}
-
-	// Parse actual job.
-	var job api.Job
-	err = json.Unmarshal(jobBytes, &job)
 	if err != nil {
-		return nil, err
+		return nil, jsonJobspecDiagnostic(raw, err)
 	}
 
 	return &job, nil
 }
 
-func jobTaskGroupsRaw(tgs []*api.TaskGroup) []interface{} {
-	ret := make([]interface{}, 0, len(tgs))
-
-	for _, tg := range tgs {
-		tgM := make(map[string]interface{})
+// jobspecDiffSuppress is the DiffSuppressFunc used by the schema to
+// check if two jobspecs are equal. The jobspecs are parsed with the parser
+// options they were written for, so the diff is also suppressed when the
+// format of an equivalent jobspec changes, for example from HCL to JSON.
+func jobspecDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	oldJSON, newJSON := d.GetChange("json")
+	oldHCL2, newHCL2 := d.GetChange("hcl2")
 
-		if tg.Name != nil {
-			tgM["name"] = *tg.Name
-		} else {
-			tgM["name"] = ""
-		}
-		if tg.Count != nil {
-			tgM["count"] = *tg.Count
-		} else {
-			tgM["count"] = 1
-		}
-		if tg.Meta != nil {
-			tgM["meta"] = tg.Meta
-		} else {
-			tgM["meta"] = make(map[string]interface{})
-		}
+	// Parse the old job
+	oldJob, err := parseJobspecForDiff(old, jobParserConfigFromValues(oldJSON, oldHCL2))
+	if err != nil {
+		log.Printf("[DEBUG] error parsing the jobspec in the state, not suppressing the diff: %s", err)
+		return false
+	}
 
-		tasksI := make([]interface{}, 0, len(tg.Tasks))
-		for _, task := range tg.Tasks {
-			taskM := make(map[string]interface{})
+	// Parse the new job
+	newJob, err := parseJobspecForDiff(new, jobParserConfigFromValues(newJSON, newHCL2))
+	if err != nil {
+		log.Printf("[DEBUG] error parsing the jobspec in the configuration, not suppressing the diff: %s", err)
+		return false
+	}
 
-			taskM["name"] = task.Name
-			taskM["driver"] = task.Driver
-			if task.Meta != nil {
-				taskM["meta"] = task.Meta
-			} else {
-				taskM["meta"] = make(map[string]interface{})
-			}
+	// Check for jobspec equality
+	preserveCounts := d.Get("preserve_counts").(bool)
+	oldNormalized, err := normalizeJobForDiff(oldJob, preserveCounts)
+	if err != nil {
+		log.Printf("[DEBUG] error normalizing the jobspec in the state, not suppressing the diff: %s", err)
+		return false
+	}
+	newNormalized, err := normalizeJobForDiff(newJob, preserveCounts)
+	if err != nil {
+		log.Printf("[DEBUG] error normalizing the jobspec in the configuration, not suppressing the diff: %s", err)
+		return false
+	}
+	return reflect.DeepEqual(oldNormalized, newNormalized)
+}
 
-			volumeMountsI := make([]interface{}, 0, len(task.VolumeMounts))
-			for _, vm := range task.VolumeMounts {
-				volumeMountM := make(map[string]interface{})
+// jobParserOptionDiffSuppress suppresses the diff of the options of the
+// jobspec parser when the jobspec is equivalent with both options, for
+// example when a job imported with a JSON jobspec is configured with HCL.
+func jobParserOptionDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	oldSpec, newSpec := d.GetChange("jobspec")
+	return jobspecDiffSuppress("jobspec", oldSpec.(string), newSpec.(string), d)
+}
 
-				volumeMountM["volume"] = vm.Volume
-				volumeMountM["destination"] = vm.Destination
-				volumeMountM["read_only"] = vm.ReadOnly
+// impliedConstraintTargets are the targets of the constraints that Nomad
+// adds to the task groups of a job when it is registered, depending on the
+// features it uses.
+var impliedConstraintTargets = map[string]bool{
+	"${attr.vault.version}":  true,
+	"${attr.consul.version}": true,
+	"${attr.nomad.version}":  true,
+	"${attr.os.signals}":     true,
+}
 
-				volumeMountsI = append(volumeMountsI, volumeMountM)
+// normalizeJobForDiff returns a representation of a job in which the jobs
+// parsed from a jobspec and the jobs read from Nomad can be compared: the
+// fields set by the server and the constraints it adds are removed, the
+// defaults are set and the zero values are dropped, as Nomad returns them
+// for the fields that are not set in the jobspec. With preserveCounts, the
+// count of the task groups with an enabled scaling policy is removed too,
+// as it is not changed when the job is registered. It is removed before
+// setting the defaults, as the minimum of the scaling policy defaults to it.
+func normalizeJobForDiff(job *api.Job, preserveCounts bool) (interface{}, error) {
+	job = jobWithoutServerFields(job)
+	if preserveCounts {
+		taskGroups := make([]*api.TaskGroup, 0, len(job.TaskGroups))
+		for _, tg := range job.TaskGroups {
+			normalized := *tg
+			if tg.Scaling != nil && (tg.Scaling.Enabled == nil || *tg.Scaling.Enabled) {
+				normalized.Count = nil
 			}
-			taskM["volume_mounts"] = volumeMountsI
-
-			tasksI = append(tasksI, taskM)
+			taskGroups = append(taskGroups, &normalized)
 		}
-		tgM["task"] = tasksI
-
-		volumesI := make([]interface{}, 0, len(tg.Volumes))
-		for _, v := range tg.Volumes {
-			volumeM := make(map[string]interface{})
-
-			volumeM["name"] = v.Name
-			volumeM["type"] = v.Type
-			volumeM["read_only"] = v.ReadOnly
-			volumeM["source"] = v.Source
-
-			volumesI = append(volumesI, volumeM)
+		job.TaskGroups = taskGroups
+	}
+	job.Canonicalize()
+
+	taskGroups := make([]*api.TaskGroup, 0, len(job.TaskGroups))
+	for _, tg := range job.TaskGroups {
+		normalized := *tg
+		normalized.Constraints = nil
+		for _, c := range tg.Constraints {
+			if !impliedConstraintTargets[c.LTarget] {
+				normalized.Constraints = append(normalized.Constraints, c)
+			}
 		}
-		sort.Slice(volumesI, func(i, j int) bool {
-			return volumesI[i].(map[string]interface{})["name"].(string) <
-				volumesI[j].(map[string]interface{})["name"].(string)
-		})
-
-		tgM["volumes"] = volumesI
-
-		ret = append(ret, tgM)
+		taskGroups = append(taskGroups, &normalized)
 	}
+	job.TaskGroups = taskGroups
 
-	return ret
-}
-
-// jobspecDiffSuppress is the DiffSuppressFunc used by the schema to
-// check if two jobspecs are equal.
-func jobspecDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
-	// TODO: does this need to consider is_json ???
-	// Parse the old job
-	oldJob, err := jobspec.Parse(strings.NewReader(old))
+	raw, err := json.Marshal(job)
 	if err != nil {
-		return false
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
 	}
+	return withoutZeroValues(value), nil
+}
 
-	// Parse the new job
-	newJob, err := jobspec.Parse(strings.NewReader(new))
-	if err != nil {
-		return false
+// withoutZeroValues removes the null, false, zero, empty string, empty list
+// and empty object values from a decoded JSON value. It returns nil if the
+// value itself is a zero value.
+func withoutZeroValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e = withoutZeroValues(e); e == nil {
+				delete(v, k)
+			} else {
+				v[k] = e
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+		return v
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		for i, e := range v {
+			v[i] = withoutZeroValues(e)
+		}
+		return v
+	case bool:
+		if !v {
+			return nil
+		}
+	case float64:
+		if v == 0 {
+			return nil
+		}
+	case string:
+		if v == "" {
+			return nil
+		}
 	}
+	return value
+}
 
-	// Init
-	oldJob.Canonicalize()
-	newJob.Canonicalize()
+// parseJobspecForDiff parses a jobspec to compare it with another one. The
+// jobspec in the state may be a JSON jobspec generated from the job
+// registered in Nomad on import, regardless of the parser options, and the
+// options read while diffing fall back to the state when they are not set
+// in the configuration. So the format of the jobspec is guessed from its
+// content when it cannot be parsed with the options.
+func parseJobspecForDiff(raw string, config JobParserConfig) (*api.Job, error) {
+	job, err := parseJobspec(raw, config, nil, nil)
+	if err == nil {
+		return job, nil
+	}
 
-	// Check for jobspec equality
-	return reflect.DeepEqual(oldJob, newJob)
+	isJSON := strings.HasPrefix(strings.TrimSpace(raw), "{")
+	if isJSON == config.JSON {
+		return nil, err
+	}
+	if guessed, guessErr := parseJobspec(raw, JobParserConfig{JSON: isJSON}, nil, nil); guessErr == nil {
+		return guessed, nil
+	}
+	return nil, err
 }
@@ -0,0 +1,243 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	hcl1 "github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	hcl1parser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/nomad/api"
+
+	"github.com/hashicorp/terraform-provider-nomad/nomad/core/jobspec"
+)
+
+// jobspecDiagnostic is an error found in a jobspec, with its location when
+// the parser reports it.
+type jobspecDiagnostic struct {
+	// Path is the path of the block the error was found in, for example
+	// `job "example"`, `group "cache"`, `task "redis"`.
+	Path []string
+
+	// Line and Column start at 1, and are 0 when the position is unknown.
+	Line   int
+	Column int
+
+	// Snippet is the line of the jobspec the error was found at.
+	Snippet string
+
+	Summary string
+}
+
+func (d *jobspecDiagnostic) Error() string {
+	var b strings.Builder
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d", d.Line, d.Column)
+	}
+	if len(d.Path) > 0 {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "(%s)", strings.Join(d.Path, " > "))
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(d.Summary)
+	if d.Snippet != "" {
+		b.WriteString("\n\n")
+		b.WriteString(d.Snippet)
+	}
+	return b.String()
+}
+
+// setPosition sets the position of the diagnostic, with the corresponding
+// snippet of src.
+func (d *jobspecDiagnostic) setPosition(src string, line, column int) {
+	d.Line, d.Column = line, column
+	d.Snippet = jobspecSnippet(src, line, column)
+}
+
+// jobspecDiagnostics returns the error reported for the diagnostics found
+// in a jobspec.
+func jobspecDiagnostics(diags []error) error {
+	return &multierror.Error{
+		Errors:      diags,
+		ErrorFormat: formatJobspecDiagnostics,
+	}
+}
+
+func formatJobspecDiagnostics(errs []error) string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d errors:\n\n%s", len(errs), strings.Join(msgs, "\n\n"))
+}
+
+// jobspecSnippet returns the line of src at the given position, with a
+// marker under the column.
+func jobspecSnippet(src string, line, column int) string {
+	lines := strings.Split(src, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimRight(strings.Replace(lines[line-1], "\t", " ", -1), " \r")
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	gutter := fmt.Sprintf("%4d | ", line)
+	marker := ""
+	if column > 0 {
+		marker = "\n" + strings.Repeat(" ", len(gutter)-2) + "| " + strings.Repeat(" ", column-1) + "^"
+	}
+	return gutter + text + marker
+}
+
+// parseHCLJobspec parses an HCL jobspec. When the jobspec is invalid, the
+// errors are reported with their location.
+func parseHCLJobspec(raw string) (*api.Job, error) {
+	job, err := jobspec.Parse(strings.NewReader(raw))
+	if err != nil {
+		return nil, hclJobspecDiagnostics(raw, err)
+	}
+	return job, nil
+}
+
+// hclJobspecDiagnostics returns the diagnostics of an HCL jobspec that
+// jobspec.Parse failed to parse with parseErr. Syntax errors are located
+// where the HCL parser reports them, while the errors found by
+// jobspec.Parse, which already name their group and task, are reported
+// together at the job block.
+func hclJobspecDiagnostics(src string, parseErr error) error {
+	root, err := hcl1.Parse(src)
+	if err != nil {
+		d := &jobspecDiagnostic{Summary: err.Error()}
+		if posErr, ok := err.(*hcl1parser.PosError); ok {
+			d.Summary = posErr.Err.Error()
+			d.setPosition(src, posErr.Pos.Line, posErr.Pos.Column)
+		}
+		return jobspecDiagnostics([]error{d})
+	}
+
+	d := &jobspecDiagnostic{Summary: strings.Join(jobspecErrorMessages(parseErr), "; ")}
+	if list, ok := root.Node.(*ast.ObjectList); ok {
+		// Filter removes the job key, which is the position of the item
+		if jobs := list.Filter("job"); len(jobs.Items) > 0 && len(jobs.Items[0].Keys) > 0 {
+			name, _ := jobs.Items[0].Keys[0].Token.Value().(string)
+			d.Path = []string{fmt.Sprintf("job %q", name)}
+		}
+		for _, item := range list.Items {
+			if len(item.Keys) > 0 && item.Keys[0].Token.Value() == "job" {
+				pos := item.Pos()
+				d.setPosition(src, pos.Line, pos.Column)
+				break
+			}
+		}
+	}
+	return jobspecDiagnostics([]error{d})
+}
+
+// jobspecErrorMessages returns the messages of the errors returned by
+// jobspec.Parse, which formats them as a list.
+func jobspecErrorMessages(err error) []string {
+	msg := strings.TrimPrefix(err.Error(), "error parsing 'job': ")
+
+	var msgs []string
+	for _, line := range strings.Split(msg, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "* ") {
+			msgs = append(msgs, strings.TrimPrefix(line, "* "))
+		}
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// hcl2JobspecDiagnostics returns the diagnostics of the errors reported by
+// the HCL2 parser, in the job, group and task blocks of body that contain
+// them.
+func hcl2JobspecDiagnostics(src string, body *hclsyntax.Body, hclDiags hcl.Diagnostics) error {
+	var diags []error
+	for _, hclDiag := range hclDiags {
+		if hclDiag.Severity != hcl.DiagError {
+			continue
+		}
+		d := &jobspecDiagnostic{Summary: hclDiag.Summary}
+		if hclDiag.Detail != "" {
+			d.Summary = fmt.Sprintf("%s; %s", hclDiag.Summary, hclDiag.Detail)
+		}
+		if hclDiag.Subject != nil {
+			d.Path = hcl2BlockPath(body, hclDiag.Subject.Start)
+			d.setPosition(src, hclDiag.Subject.Start.Line, hclDiag.Subject.Start.Column)
+		}
+		diags = append(diags, d)
+	}
+	return jobspecDiagnostics(diags)
+}
+
+// hcl2BlockPath returns the path of the job, group and task blocks of body
+// that contain pos.
+func hcl2BlockPath(body *hclsyntax.Body, pos hcl.Pos) []string {
+	var path []string
+	for body != nil {
+		var next *hclsyntax.Body
+		for _, block := range body.Blocks {
+			if !block.Range().ContainsPos(pos) {
+				continue
+			}
+			switch block.Type {
+			case "job", "group", "task":
+				if len(block.Labels) == 1 {
+					path = append(path, fmt.Sprintf("%s %q", block.Type, block.Labels[0]))
+				}
+			}
+			next = block.Body
+			break
+		}
+		body = next
+	}
+	return path
+}
+
+// jsonJobspecDiagnostic returns the diagnostic of an error returned by
+// encoding/json while parsing a JSON jobspec.
+func jsonJobspecDiagnostic(src string, err error) error {
+	d := &jobspecDiagnostic{Summary: err.Error()}
+
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		d.Summary = strings.TrimPrefix(e.Error(), "json: ")
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		d.Summary = fmt.Sprintf("cannot use a %s value as %s", e.Value, e.Type)
+		if e.Field != "" {
+			d.Path = strings.Split(e.Field, ".")
+		}
+		offset = e.Offset
+	default:
+		return err
+	}
+
+	// The offset is the one of the byte after the error
+	if offset > int64(len(src)) {
+		offset = int64(len(src))
+	}
+	if offset < 1 {
+		offset = 1
+	}
+	before := src[:offset-1]
+	d.setPosition(src, strings.Count(before, "\n")+1, len(before)-strings.LastIndex(before, "\n"))
+	return jobspecDiagnostics([]error{d})
+}
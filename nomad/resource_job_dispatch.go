@@ -0,0 +1,215 @@
+package nomad
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceJobDispatch() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceJobDispatchCreate,
+		Delete: resourceJobDispatchDelete,
+		Read:   resourceJobDispatchRead,
+
+		Schema: map[string]*schema.Schema{
+			"job_id": {
+				Description: "The ID of the parameterized job to dispatch.",
+				Required:    true,
+				ForceNew:    true,
+				Type:        schema.TypeString,
+			},
+
+			"namespace": {
+				Description: "The namespace of the parameterized job.",
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+				Type:        schema.TypeString,
+			},
+
+			"meta": {
+				Description: "Metadata to dispatch the job with.",
+				Optional:    true,
+				ForceNew:    true,
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"payload": {
+				Description:   "The payload to dispatch the job with.",
+				Optional:      true,
+				ForceNew:      true,
+				Sensitive:     true,
+				Type:          schema.TypeString,
+				ConflictsWith: []string{"payload_base64"},
+			},
+
+			"payload_base64": {
+				Description:   "The base64 encoded payload to dispatch the job with, for binary payloads.",
+				Optional:      true,
+				ForceNew:      true,
+				Sensitive:     true,
+				Type:          schema.TypeString,
+				ConflictsWith: []string{"payload"},
+			},
+
+			"wait_for_completion": {
+				Description: "If true, wait until all the allocations of the dispatched job are complete and fail if any of them failed.",
+				Optional:    true,
+				ForceNew:    true,
+				Type:        schema.TypeBool,
+			},
+
+			"dispatched_job_id": {
+				Description: "The ID of the dispatched job.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"eval_id": {
+				Description: "The ID of the evaluation created by the dispatch.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"status": {
+				Description: "The status of the dispatched job.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"exit_codes": {
+				Description: "The exit code of the last run of each task of the dispatched job, by \"<group>.<task>\".",
+				Computed:    true,
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+			},
+		},
+	}
+}
+
+func resourceJobDispatchCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(ProviderConfig).client
+
+	jobID := d.Get("job_id").(string)
+	namespace := d.Get("namespace").(string)
+
+	var payload []byte
+	if v, ok := d.GetOk("payload_base64"); ok {
+		var err error
+		payload, err = base64.StdEncoding.DecodeString(v.(string))
+		if err != nil {
+			return fmt.Errorf("error decoding payload_base64: %s", err)
+		}
+	} else {
+		payload = []byte(d.Get("payload").(string))
+	}
+
+	jobMeta := make(map[string]string)
+	for k, v := range d.Get("meta").(map[string]interface{}) {
+		jobMeta[k] = v.(string)
+	}
+
+	log.Printf("[DEBUG] Dispatching job %q in namespace %q", jobID, namespace)
+	resp, _, err := client.Jobs().Dispatch(jobID, jobMeta, payload, &api.WriteOptions{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("error dispatching job %q: %s", jobID, err)
+	}
+	log.Printf("[DEBUG] Dispatched job %q as %q", jobID, resp.DispatchedJobID)
+
+	d.SetId(resp.DispatchedJobID)
+	d.Set("dispatched_job_id", resp.DispatchedJobID)
+	d.Set("eval_id", resp.EvalID)
+
+	if d.Get("wait_for_completion").(bool) {
+		log.Printf("[DEBUG] Waiting for completion of dispatched job %q", resp.DispatchedJobID)
+		err := monitorBatchJob(client, d.Timeout(schema.TimeoutCreate), resp.EvalID, resp.DispatchedJobID, namespace, 0)
+		if err != nil {
+			// read the final status of the job before reporting the error,
+			// the resource is tainted and can be dispatched again
+			if readErr := resourceJobDispatchRead(d, meta); readErr != nil {
+				log.Printf("[WARN] failed to read dispatched job %q: %s", resp.DispatchedJobID, readErr)
+			}
+			return fmt.Errorf("error waiting for dispatched job %q to complete: %s", resp.DispatchedJobID, err)
+		}
+	}
+
+	return resourceJobDispatchRead(d, meta)
+}
+
+// resourceJobDispatchDelete only removes the dispatched job from the state:
+// it runs to completion and is then garbage collected by Nomad.
+func resourceJobDispatchDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[DEBUG] Removing dispatched job %q from the state", d.Id())
+	d.SetId("")
+	return nil
+}
+
+func resourceJobDispatchRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(ProviderConfig).client
+
+	log.Printf("[DEBUG] Reading dispatched job %q", d.Id())
+	if err := readChildJob(d, client, d.Id()); err != nil {
+		return err
+	}
+	d.Set("dispatched_job_id", d.Id())
+
+	return nil
+}
+
+// readChildJob sets the status and exit_codes attributes of the resources
+// that launch a child of a parameterized or periodic job.
+func readChildJob(d *schema.ResourceData, client *api.Client, id string) error {
+	q := &api.QueryOptions{Namespace: d.Get("namespace").(string)}
+
+	job, _, err := client.Jobs().Info(id, q)
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			// Child jobs are garbage collected once they are done, keep the
+			// last known state instead of launching the job again.
+			log.Printf("[DEBUG] Job %q has been garbage collected", id)
+			return nil
+		}
+		return fmt.Errorf("error reading job %q: %s", id, err)
+	}
+
+	allocs, _, err := client.Jobs().Allocations(id, false, q)
+	if err != nil {
+		return fmt.Errorf("error reading allocations of job %q: %s", id, err)
+	}
+
+	d.Set("status", job.Status)
+	d.Set("exit_codes", allocationsExitCodes(allocs))
+
+	return nil
+}
+
+// allocationsExitCodes returns the exit code of the last run of each task of
+// the allocations, by "<group>.<task>". When a task group has several
+// allocations, the exit codes of the most recent allocation are reported.
+func allocationsExitCodes(allocs []*api.AllocationListStub) map[string]interface{} {
+	sorted := make([]*api.AllocationListStub, len(allocs))
+	copy(sorted, allocs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreateIndex < sorted[j].CreateIndex })
+
+	codes := make(map[string]interface{})
+	for _, a := range sorted {
+		for name, state := range a.TaskStates {
+			if state == nil {
+				continue
+			}
+			if code, ok := taskExitCode(state); ok {
+				codes[a.TaskGroup+"."+name] = code
+			}
+		}
+	}
+	return codes
+}
@@ -0,0 +1,160 @@
+package nomad
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	r "github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceJobDispatch_basic(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJobDispatch_config("0"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttrSet("nomad_job_dispatch.test", "dispatched_job_id"),
+					r.TestCheckResourceAttrSet("nomad_job_dispatch.test", "eval_id"),
+					r.TestCheckResourceAttr("nomad_job_dispatch.test", "status", "dead"),
+					r.TestCheckResourceAttr("nomad_job_dispatch.test", "exit_codes.foo.foo", "0"),
+					testResourceJobDispatch_checkMeta("nomad_job_dispatch.test"),
+				),
+			},
+		},
+		CheckDestroy: testResourceJob_forceDestroyWithPurge("dispatch-test", "default"),
+	})
+}
+
+func TestResourceJobDispatch_failure(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config:      testResourceJobDispatch_config("3"),
+				ExpectError: regexp.MustCompile(`task "foo" is dead \(failed\), exit code 3`),
+			},
+		},
+		CheckDestroy: testResourceJob_forceDestroyWithPurge("dispatch-test", "default"),
+	})
+}
+
+func testResourceJobDispatch_checkMeta(name string) r.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("%s not found in state", name)
+		}
+
+		client := testProvider.Meta().(ProviderConfig).client
+		job, _, err := client.Jobs().Info(rs.Primary.ID, nil)
+		if err != nil {
+			return fmt.Errorf("error reading dispatched job: %s", err)
+		}
+		if *job.ParentID != "dispatch-test" {
+			return fmt.Errorf("expected parent %q, got %q", "dispatch-test", *job.ParentID)
+		}
+		if job.Meta["run"] != "terraform" {
+			return fmt.Errorf("expected meta run=terraform, got %#v", job.Meta)
+		}
+		return nil
+	}
+}
+
+func TestAllocationsExitCodes(t *testing.T) {
+	terminated := func(code string) *api.TaskState {
+		return &api.TaskState{
+			State: "dead",
+			Events: []*api.TaskEvent{
+				{Type: api.TaskTerminated, Details: map[string]string{"exit_code": code}},
+			},
+		}
+	}
+
+	allocs := []*api.AllocationListStub{
+		{
+			TaskGroup:   "foo",
+			CreateIndex: 20,
+			TaskStates: map[string]*api.TaskState{
+				"foo": terminated("0"),
+			},
+		},
+		{
+			TaskGroup:   "foo",
+			CreateIndex: 10,
+			TaskStates: map[string]*api.TaskState{
+				"foo": terminated("1"),
+				"bar": terminated("2"),
+			},
+		},
+		{
+			TaskGroup:   "baz",
+			CreateIndex: 30,
+			TaskStates: map[string]*api.TaskState{
+				"baz": {State: "running"},
+			},
+		},
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"foo.foo": 0,
+		"foo.bar": 2,
+	}, allocationsExitCodes(allocs))
+}
+
+func testResourceJobDispatch_config(exitCode string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "parameterized" {
+	jobspec = <<EOT
+		job "dispatch-test" {
+			datacenters = ["dc1"]
+			type = "batch"
+			parameterized {
+				payload       = "required"
+				meta_required = ["run"]
+			}
+			group "foo" {
+				reschedule {
+					attempts  = 0
+					unlimited = false
+				}
+				restart {
+					attempts = 0
+					mode     = "fail"
+				}
+				task "foo" {
+					driver = "raw_exec"
+					config {
+						command = "/bin/sh"
+						args    = ["-c", "cat $${NOMAD_TASK_DIR}/payload; exit %s"]
+					}
+					dispatch_payload {
+						file = "payload"
+					}
+					resources {
+						cpu = 100
+						memory = 10
+					}
+				}
+			}
+		}
+	EOT
+}
+
+resource "nomad_job_dispatch" "test" {
+	job_id              = nomad_job.parameterized.id
+	payload             = "hello"
+	wait_for_completion = true
+
+	meta = {
+		run = "terraform"
+	}
+}
+`, exitCode)
+}
@@ -0,0 +1,436 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/dynblock"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/nomad/api"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/hashicorp/terraform-provider-nomad/nomad/core/jobspec"
+)
+
+// hcl2Functions are the functions that can be called from an HCL2 jobspec.
+var hcl2Functions = map[string]function.Function{
+	"abs":                    stdlib.AbsoluteFunc,
+	"coalesce":               stdlib.CoalesceFunc,
+	"concat":                 stdlib.ConcatFunc,
+	"csvdecode":              stdlib.CSVDecodeFunc,
+	"format":                 stdlib.FormatFunc,
+	"formatdate":             stdlib.FormatDateFunc,
+	"formatlist":             stdlib.FormatListFunc,
+	"jsondecode":             stdlib.JSONDecodeFunc,
+	"jsonencode":             stdlib.JSONEncodeFunc,
+	"length":                 stdlib.LengthFunc,
+	"lower":                  stdlib.LowerFunc,
+	"max":                    stdlib.MaxFunc,
+	"min":                    stdlib.MinFunc,
+	"range":                  stdlib.RangeFunc,
+	"regex":                  stdlib.RegexFunc,
+	"regexall":               stdlib.RegexAllFunc,
+	"reverse":                stdlib.ReverseFunc,
+	"setintersection":        stdlib.SetIntersectionFunc,
+	"setsubtract":            stdlib.SetSubtractFunc,
+	"setsymmetricdifference": stdlib.SetSymmetricDifferenceFunc,
+	"setunion":               stdlib.SetUnionFunc,
+	"substr":                 stdlib.SubstrFunc,
+	"upper":                  stdlib.UpperFunc,
+}
+
+// hcl2File is the content of an HCL2 jobspec.
+type hcl2File struct {
+	Variables []*hcl2Variable `hcl:"variable,block"`
+	Locals    []*hcl2Locals   `hcl:"locals,block"`
+	Job       *hcl2Job        `hcl:"job,block"`
+}
+
+type hcl2Variable struct {
+	Name        string         `hcl:"name,label"`
+	Type        *hcl.Attribute `hcl:"type,optional"`
+	Default     *hcl.Attribute `hcl:"default,optional"`
+	Description string         `hcl:"description,optional"`
+
+	// DefRange is the range of the definition of the variable block, which
+	// gohcl does not decode.
+	DefRange hcl.Range
+}
+
+type hcl2Locals struct {
+	Attributes hcl.Attributes `hcl:",remain"`
+}
+
+type hcl2Job struct {
+	Name string   `hcl:"name,label"`
+	Body hcl.Body `hcl:",remain"`
+}
+
+// parseHCL2Jobspec parses an HCL2 jobspec locally. Input variables, locals,
+// functions and dynamic blocks are evaluated with the given variables, and
+// the resulting job is then decoded with the HCL1 jobspec parser so both
+// formats share the same semantics. References to anything else than the
+// variables, the locals and the iterators of dynamic blocks are errors.
+func parseHCL2Jobspec(raw string, vars map[string]string) (*api.Job, error) {
+	file, diags := hclsyntax.ParseConfig([]byte(raw), "jobspec.hcl", hcl.Pos{Line: 1, Column: 1})
+	body, _ := file.Body.(*hclsyntax.Body)
+	if diags.HasErrors() {
+		return nil, hcl2JobspecDiagnostics(raw, body, diags)
+	}
+
+	var content hcl2File
+	if diags := gohcl.DecodeBody(body, nil, &content); diags.HasErrors() {
+		return nil, hcl2JobspecDiagnostics(raw, body, diags)
+	}
+	var variableBlocks []*hclsyntax.Block
+	for _, block := range body.Blocks {
+		if block.Type == "variable" {
+			variableBlocks = append(variableBlocks, block)
+		}
+	}
+	for i, v := range content.Variables {
+		v.DefRange = variableBlocks[i].DefRange()
+	}
+	if content.Job == nil {
+		return nil, fmt.Errorf("jobspec.hcl: a job block is required")
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{},
+		Functions: hcl2Functions,
+	}
+
+	varValues, diags := decodeHCL2Variables(content.Variables, vars)
+	if diags.HasErrors() {
+		return nil, hcl2JobspecDiagnostics(raw, body, diags)
+	}
+	ctx.Variables["var"] = cty.ObjectVal(varValues)
+
+	localValues, diags := decodeHCL2Locals(content.Locals, ctx)
+	if diags.HasErrors() {
+		return nil, hcl2JobspecDiagnostics(raw, body, diags)
+	}
+	ctx.Variables["local"] = cty.ObjectVal(localValues)
+
+	jobBody := content.Job.Body.(*hclsyntax.Body)
+	job, diags := hcl2BodyValue(jobBody, dynblock.Expand(jobBody, ctx), ctx)
+	if diags.HasErrors() {
+		return nil, hcl2JobspecDiagnostics(raw, body, diags)
+	}
+
+	// The HCL1 parser reads the JSON syntax, in which blocks are objects
+	// keyed by their labels.
+	src, err := json.Marshal(map[string]interface{}{
+		"job": map[string]interface{}{content.Job.Name: job},
+	})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := jobspec.Parse(strings.NewReader(string(src)))
+	if err != nil {
+		return nil, hcl2JobspecDiagnostics(raw, body, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  strings.Join(jobspecErrorMessages(err), "; "),
+			Subject:  hcl2JobDefRange(body),
+		}})
+	}
+	return parsed, nil
+}
+
+// hcl2JobDefRange returns the range of the definition of the job block.
+func hcl2JobDefRange(body *hclsyntax.Body) *hcl.Range {
+	for _, block := range body.Blocks {
+		if block.Type == "job" {
+			r := block.DefRange()
+			return &r
+		}
+	}
+	return nil
+}
+
+// decodeHCL2Variables returns the value of each variable declared in the
+// jobspec, using the value given in vars if any, or its default otherwise.
+func decodeHCL2Variables(variables []*hcl2Variable, vars map[string]string) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	values := make(map[string]cty.Value, len(variables))
+
+	for _, v := range variables {
+		if _, ok := values[v.Name]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate variable",
+				Detail:   fmt.Sprintf("The variable %q is declared more than once.", v.Name),
+				Subject:  v.DefRange.Ptr(),
+			})
+			continue
+		}
+
+		ty := cty.DynamicPseudoType
+		if v.Type != nil {
+			t, typeDiags := typeexpr.TypeConstraint(v.Type.Expr)
+			if diags = append(diags, typeDiags...); typeDiags.HasErrors() {
+				continue
+			}
+			ty = t
+		}
+
+		var val cty.Value
+		subject := v.DefRange.Ptr()
+		if raw, ok := vars[v.Name]; ok {
+			parsed, valDiags := parseHCL2VariableValue(raw, ty)
+			if diags = append(diags, valDiags...); valDiags.HasErrors() {
+				continue
+			}
+			val = parsed
+		} else if v.Default != nil {
+			def, valDiags := v.Default.Expr.Value(nil)
+			if diags = append(diags, valDiags...); valDiags.HasErrors() {
+				continue
+			}
+			val, subject = def, v.Default.Expr.Range().Ptr()
+		} else {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing variable value",
+				Detail:   fmt.Sprintf("The variable %q is required but no value was given.", v.Name),
+				Subject:  v.DefRange.Ptr(),
+			})
+			continue
+		}
+
+		val, err := convert.Convert(val, ty)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid variable value",
+				Detail:   fmt.Sprintf("Invalid value for variable %q: %s.", v.Name, err),
+				Subject:  subject,
+			})
+			continue
+		}
+		values[v.Name] = val
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := values[name]; !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Undeclared variable",
+				Detail:   fmt.Sprintf("A value was given for the undeclared variable %q.", name),
+			})
+		}
+	}
+
+	return values, diags
+}
+
+// parseHCL2VariableValue converts the string value of a variable given in the
+// Terraform configuration to the type of the variable. Non-string values are
+// parsed as HCL expressions, for example `["a", "b"]` or `{ a = 1 }`.
+func parseHCL2VariableValue(raw string, ty cty.Type) (cty.Value, hcl.Diagnostics) {
+	if ty == cty.String || ty == cty.DynamicPseudoType {
+		return cty.StringVal(raw), nil
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "var", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return expr.Value(nil)
+}
+
+// decodeHCL2Locals evaluates the locals of the jobspec. Locals can
+// reference each other, so they are evaluated once all their dependencies
+// are known.
+func decodeHCL2Locals(blocks []*hcl2Locals, ctx *hcl.EvalContext) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	pending := map[string]*hcl.Attribute{}
+	for _, block := range blocks {
+		for name, attr := range block.Attributes {
+			if _, ok := pending[name]; ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate local",
+					Detail:   fmt.Sprintf("The local %q is declared more than once.", name),
+					Subject:  attr.NameRange.Ptr(),
+				})
+				continue
+			}
+			pending[name] = attr
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	values := map[string]cty.Value{}
+	for len(pending) > 0 {
+		var ready []*hcl.Attribute
+		for _, attr := range pending {
+			if localDependenciesKnown(attr.Expr, pending) {
+				ready = append(ready, attr)
+			}
+		}
+
+		// The locals left reference each other, evaluating them reports
+		// the references to the locals that are not known yet
+		if len(ready) == 0 {
+			for _, attr := range pending {
+				ready = append(ready, attr)
+			}
+		}
+
+		for _, attr := range ready {
+			ctx.Variables["local"] = cty.ObjectVal(values)
+			v, valDiags := attr.Expr.Value(ctx)
+			if diags = append(diags, valDiags...); valDiags.HasErrors() {
+				return nil, diags
+			}
+			values[attr.Name] = v
+			delete(pending, attr.Name)
+		}
+	}
+
+	return values, diags
+}
+
+// localDependenciesKnown returns whether none of the locals referenced by
+// expr is still pending.
+func localDependenciesKnown(expr hcl.Expression, pending map[string]*hcl.Attribute) bool {
+	for _, t := range expr.Variables() {
+		if t.RootName() != "local" || len(t) < 2 {
+			continue
+		}
+		if attr, ok := t[1].(hcl.TraverseAttr); ok && pending[attr.Name] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hcl2BodyValue evaluates the attributes and the blocks of body, in which
+// the dynamic blocks are expanded, and returns its content in the JSON
+// syntax of HCL1. syntax is the body as written in the jobspec, it gives
+// the names of the attributes and the blocks.
+func hcl2BodyValue(syntax *hclsyntax.Body, body hcl.Body, ctx *hcl.EvalContext) (map[string]interface{}, hcl.Diagnostics) {
+	schema := &hcl.BodySchema{}
+	for name := range syntax.Attributes {
+		schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: name})
+	}
+
+	// The syntax of the blocks by their definition range, which is the one
+	// of the dynamic block for the blocks it generates
+	syntaxBlocks := map[hcl.Range]*hclsyntax.Body{}
+	blockTypes := map[string]bool{}
+	for _, block := range syntax.Blocks {
+		typ, labels, blockBody := block.Type, len(block.Labels), block.Body
+		if block.Type == "dynamic" && len(block.Labels) == 1 {
+			typ, labels, blockBody = block.Labels[0], 0, nil
+			if attr, ok := block.Body.Attributes["labels"]; ok {
+				if tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok {
+					labels = len(tuple.Exprs)
+				}
+			}
+			for _, content := range block.Body.Blocks {
+				if content.Type == "content" {
+					blockBody = content.Body
+				}
+			}
+		}
+		syntaxBlocks[block.AsHCLBlock().DefRange] = blockBody
+		if typ == "dynamic" || blockTypes[typ] {
+			continue
+		}
+		blockTypes[typ] = true
+		schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{
+			Type:       typ,
+			LabelNames: make([]string, labels),
+		})
+	}
+
+	content, diags := body.Content(schema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	value := make(map[string]interface{}, len(content.Attributes)+len(content.Blocks))
+	for name, attr := range content.Attributes {
+		v, valDiags := attr.Expr.Value(ctx)
+		if diags = append(diags, valDiags...); valDiags.HasErrors() || v.IsNull() {
+			continue
+		}
+		v = hcl2WithoutNulls(v)
+		raw, err := ctyjson.Marshal(v, v.Type())
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value",
+				Detail:   fmt.Sprintf("Invalid value for %q: %s.", name, err),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		value[name] = json.RawMessage(raw)
+	}
+
+	for _, block := range content.Blocks {
+		blockSyntax := syntaxBlocks[block.DefRange]
+		if blockSyntax == nil {
+			continue
+		}
+		blockValue, blockDiags := hcl2BodyValue(blockSyntax, block.Body, ctx)
+		if diags = append(diags, blockDiags...); blockDiags.HasErrors() {
+			continue
+		}
+
+		var v interface{} = blockValue
+		for i := len(block.Labels) - 1; i >= 0; i-- {
+			v = map[string]interface{}{block.Labels[i]: v}
+		}
+		blocks, _ := value[block.Type].([]interface{})
+		value[block.Type] = append(blocks, v)
+	}
+
+	return value, diags
+}
+
+// hcl2WithoutNulls removes the null elements and attributes of v, which the
+// JSON syntax of HCL1 does not support.
+func hcl2WithoutNulls(v cty.Value) cty.Value {
+	ty := v.Type()
+	switch {
+	case !v.IsKnown() || v.IsNull():
+		return v
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		var elems []cty.Value
+		for it := v.ElementIterator(); it.Next(); {
+			if _, e := it.Element(); !e.IsNull() {
+				elems = append(elems, hcl2WithoutNulls(e))
+			}
+		}
+		return cty.TupleVal(elems)
+	case ty.IsMapType() || ty.IsObjectType():
+		attrs := map[string]cty.Value{}
+		for it := v.ElementIterator(); it.Next(); {
+			if k, e := it.Element(); !e.IsNull() {
+				attrs[k.AsString()] = hcl2WithoutNulls(e)
+			}
+		}
+		return cty.ObjectVal(attrs)
+	}
+	return v
+}
@@ -0,0 +1,187 @@
+package nomad
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+// Deployment statuses, as defined in nomad/structs.
+const (
+	deploymentStatusRunning    = "running"
+	deploymentStatusFailed     = "failed"
+	deploymentStatusSuccessful = "successful"
+	deploymentStatusCancelled  = "cancelled"
+	deploymentStatusPending    = "pending"
+	deploymentStatusBlocked    = "blocked"
+	deploymentStatusUnblocking = "unblocking"
+)
+
+// Values of the on_failure field of the multiregion strategy.
+const (
+	multiregionOnFailureFailAll   = "fail_all"
+	multiregionOnFailureFailLocal = "fail_local"
+)
+
+// monitorMultiregionDeployment monitors the deployments of a multiregion job
+// in each of its regions, until they are all successful or until the
+// failure of a region settles according to the on_failure strategy of the
+// job. It returns the last known deployment of each region.
+func monitorMultiregionDeployment(client *api.Client, timeout time.Duration, job *api.Job, autoPromote bool) (map[string]*api.Deployment, error) {
+	regions := make([]string, 0, len(job.Multiregion.Regions))
+	for _, r := range job.Multiregion.Regions {
+		regions = append(regions, r.Name)
+	}
+
+	onFailure := ""
+	if s := job.Multiregion.Strategy; s != nil && s.OnFailure != nil {
+		onFailure = *s.OnFailure
+	}
+
+	deployments := make(map[string]*api.Deployment)
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{MonitoringDeployment},
+		Target:     []string{DeploymentSuccessful, DeploymentNeedsPromotion},
+		Refresh:    multiregionDeploymentStateRefreshFunc(client, job, regions, onFailure, autoPromote, deployments),
+		Timeout:    timeout,
+		Delay:      0,
+		MinTimeout: 5 * time.Second,
+	}
+
+	_, err := stateConf.WaitForState()
+	if err != nil {
+		return deployments, fmt.Errorf("error waiting for multiregion deployment: %s", err)
+	}
+	return deployments, nil
+}
+
+// multiregionDeploymentStateRefreshFunc returns a resource.StateRefreshFunc
+// that is used to watch the deployments of a multiregion job. The last
+// deployment of each region is recorded in deployments.
+func multiregionDeploymentStateRefreshFunc(client *api.Client, job *api.Job, regions []string, onFailure string,
+	autoPromote bool, deployments map[string]*api.Deployment) resource.StateRefreshFunc {
+
+	return func() (interface{}, string, error) {
+		for _, region := range regions {
+			q := &api.QueryOptions{Region: region, Namespace: *job.Namespace}
+
+			// The version of the job is specific to each region
+			regional, _, err := client.Jobs().Info(*job.ID, q)
+			if err != nil {
+				log.Printf("[ERROR] error on Jobs.Info in region %q during multiregionDeploymentStateRefresh: %s", region, err)
+				return nil, "", err
+			}
+
+			deployment, _, err := client.Jobs().LatestDeployment(*job.ID, q)
+			if err != nil {
+				log.Printf("[ERROR] error on Jobs.LatestDeployment in region %q during multiregionDeploymentStateRefresh: %s", region, err)
+				return nil, "", err
+			}
+			if deployment == nil || deployment.JobVersion != *regional.Version {
+				log.Printf("[DEBUG] no deployment yet for job '%s' in region %q", *job.ID, region)
+				delete(deployments, region)
+				continue
+			}
+
+			if autoPromote && deploymentReadyForPromotion(deployment) {
+				log.Printf("[DEBUG] promoting canaries of deployment '%s' in region %q", deployment.ID, region)
+				_, _, err := client.Deployments().PromoteAll(deployment.ID, &api.WriteOptions{
+					Region:    region,
+					Namespace: deployment.Namespace,
+				})
+				if err != nil {
+					log.Printf("[ERROR] error on Deployment.PromoteAll during multiregionDeploymentStateRefresh: %s", err)
+					return deployments, "", err
+				}
+			}
+			deployments[region] = deployment
+		}
+
+		state, err := multiregionDeploymentState(regions, deployments, onFailure)
+		return deployments, state, err
+	}
+}
+
+// multiregionDeploymentState returns the monitoring state of a multiregion
+// deployment from the deployment of each region.
+//
+// Regions past max_parallel have no deployment, or a pending one, until
+// earlier regions complete, and successful regions are blocked until all the
+// regions complete. When a region fails, the other regions are failed with
+// on_failure = "fail_all", keep deploying with "fail_local", and stay pending
+// or blocked until they are unblocked by an operator by default.
+func multiregionDeploymentState(regions []string, deployments map[string]*api.Deployment, onFailure string) (string, error) {
+	var failed []string
+	waiting := false
+	needsPromotion := false
+
+	for _, region := range regions {
+		d := deployments[region]
+		if d == nil {
+			waiting = waiting || onFailure == multiregionOnFailureFailLocal
+			continue
+		}
+
+		switch d.Status {
+		case deploymentStatusSuccessful:
+		case deploymentStatusFailed, deploymentStatusCancelled:
+			failed = append(failed, fmt.Sprintf("region %q: deployment '%s' terminated with status '%s': '%s'",
+				region, d.ID, d.Status, d.StatusDescription))
+		case deploymentStatusPending, deploymentStatusBlocked:
+			waiting = waiting || onFailure == multiregionOnFailureFailLocal
+		case deploymentStatusRunning:
+			if deploymentReadyForPromotion(d) {
+				needsPromotion = true
+			} else {
+				waiting = true
+			}
+		default:
+			waiting = true
+		}
+	}
+
+	if len(failed) == 0 {
+		for _, region := range regions {
+			if d := deployments[region]; d == nil || d.Status != deploymentStatusSuccessful {
+				if needsPromotion && !waiting {
+					return DeploymentNeedsPromotion, nil
+				}
+				return MonitoringDeployment, nil
+			}
+		}
+		return DeploymentSuccessful, nil
+	}
+
+	if waiting {
+		return MonitoringDeployment, nil
+	}
+
+	sort.Strings(failed)
+	return "", fmt.Errorf("multiregion deployment failed:\n%s", strings.Join(failed, "\n"))
+}
+
+// flattenMultiregionDeployments returns the deployment of each region of a
+// multiregion job in the format of the multiregion_deployments attribute.
+func flattenMultiregionDeployments(job *api.Job, deployments map[string]*api.Deployment) []interface{} {
+	result := make([]interface{}, 0, len(job.Multiregion.Regions))
+	for _, r := range job.Multiregion.Regions {
+		entry := map[string]interface{}{
+			"region":             r.Name,
+			"deployment_id":      "",
+			"status":             "",
+			"status_description": "",
+		}
+		if d := deployments[r.Name]; d != nil {
+			entry["deployment_id"] = d.ID
+			entry["status"] = d.Status
+			entry["status_description"] = d.StatusDescription
+		}
+		result = append(result, entry)
+	}
+	return result
+}
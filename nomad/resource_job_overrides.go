@@ -0,0 +1,178 @@
+package nomad
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// jobOverridesSchema returns the schema of the overrides block of nomad_job.
+func jobOverridesSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Values applied to the parsed jobspec before it is registered.",
+		Optional:    true,
+		MaxItems:    1,
+		Type:        schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"datacenters": {
+					Description: "The datacenters of the job.",
+					Optional:    true,
+					Type:        schema.TypeList,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+				},
+				"meta": {
+					Description: "Metadata merged into the metadata of the job.",
+					Optional:    true,
+					Type:        schema.TypeMap,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+				},
+				"group": {
+					Description: "Overrides of a task group.",
+					Optional:    true,
+					Type:        schema.TypeList,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"name": {
+								Description: "The name of the task group.",
+								Required:    true,
+								Type:        schema.TypeString,
+							},
+							"count": {
+								Description:  "The count of the task group. -1 keeps the count of the jobspec.",
+								Optional:     true,
+								Default:      -1,
+								Type:         schema.TypeInt,
+								ValidateFunc: validation.IntAtLeast(-1),
+							},
+							"meta": {
+								Description: "Metadata merged into the metadata of the task group.",
+								Optional:    true,
+								Type:        schema.TypeMap,
+								Elem:        &schema.Schema{Type: schema.TypeString},
+							},
+							"task": {
+								Description: "Overrides of a task of the group.",
+								Optional:    true,
+								Type:        schema.TypeList,
+								Elem: &schema.Resource{
+									Schema: map[string]*schema.Schema{
+										"name": {
+											Description: "The name of the task.",
+											Required:    true,
+											Type:        schema.TypeString,
+										},
+										"image": {
+											Description: "The image set in the task config.",
+											Optional:    true,
+											Type:        schema.TypeString,
+										},
+										"env": {
+											Description: "Environment variables merged into the environment of the task.",
+											Optional:    true,
+											Type:        schema.TypeMap,
+											Elem:        &schema.Schema{Type: schema.TypeString},
+										},
+										"meta": {
+											Description: "Metadata merged into the metadata of the task.",
+											Optional:    true,
+											Type:        schema.TypeMap,
+											Elem:        &schema.Schema{Type: schema.TypeString},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// applyJobOverrides applies the overrides block of the resource to a job
+// parsed from its jobspec. Overrides referencing a task group or a task that
+// is not in the job are an error, so that typos do not go unnoticed.
+func applyJobOverrides(job *api.Job, d resourceFieldGetter) error {
+	overrides, ok := d.Get("overrides").([]interface{})
+	if !ok || len(overrides) == 0 || overrides[0] == nil {
+		return nil
+	}
+	o := overrides[0].(map[string]interface{})
+
+	if dcs, ok := o["datacenters"].([]interface{}); ok && len(dcs) > 0 {
+		job.Datacenters = make([]string, 0, len(dcs))
+		for _, dc := range dcs {
+			job.Datacenters = append(job.Datacenters, dc.(string))
+		}
+	}
+	job.Meta = mergeStringMap(job.Meta, o["meta"])
+
+	groups, _ := o["group"].([]interface{})
+	for _, g := range groups {
+		group := g.(map[string]interface{})
+		name := group["name"].(string)
+
+		var tg *api.TaskGroup
+		for _, candidate := range job.TaskGroups {
+			if candidate.Name != nil && *candidate.Name == name {
+				tg = candidate
+				break
+			}
+		}
+		if tg == nil {
+			return fmt.Errorf("error applying overrides: task group %q not found in job", name)
+		}
+
+		if count, ok := group["count"].(int); ok && count >= 0 {
+			tg.Count = &count
+		}
+		tg.Meta = mergeStringMap(tg.Meta, group["meta"])
+
+		tasks, _ := group["task"].([]interface{})
+		for _, t := range tasks {
+			task := t.(map[string]interface{})
+			taskName := task["name"].(string)
+
+			var target *api.Task
+			for _, candidate := range tg.Tasks {
+				if candidate.Name == taskName {
+					target = candidate
+					break
+				}
+			}
+			if target == nil {
+				return fmt.Errorf("error applying overrides: task %q not found in task group %q", taskName, name)
+			}
+
+			if image, ok := task["image"].(string); ok && image != "" {
+				if target.Config == nil {
+					target.Config = make(map[string]interface{})
+				}
+				target.Config["image"] = image
+			}
+			target.Env = mergeStringMap(target.Env, task["env"])
+			target.Meta = mergeStringMap(target.Meta, task["meta"])
+		}
+	}
+
+	return nil
+}
+
+// mergeStringMap merges the values of a TypeMap attribute into m, allocating
+// it if needed.
+func mergeStringMap(m map[string]string, raw interface{}) map[string]string {
+	values, ok := raw.(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string, len(values))
+	}
+	for k, v := range values {
+		m[k] = v.(string)
+	}
+	return m
+}
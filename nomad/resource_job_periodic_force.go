@@ -0,0 +1,138 @@
+package nomad
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceJobPeriodicForce() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceJobPeriodicForceCreate,
+		Delete: resourceJobPeriodicForceDelete,
+		Read:   resourceJobPeriodicForceRead,
+
+		Schema: map[string]*schema.Schema{
+			"job_id": {
+				Description: "The ID of the periodic job to launch.",
+				Required:    true,
+				ForceNew:    true,
+				Type:        schema.TypeString,
+			},
+
+			"namespace": {
+				Description: "The namespace of the periodic job.",
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+				Type:        schema.TypeString,
+			},
+
+			"triggers": {
+				Description: "Arbitrary values that, when changed, launch the periodic job again.",
+				Optional:    true,
+				ForceNew:    true,
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"wait_for_completion": {
+				Description: "If true, wait until all the allocations of the launched job are complete and fail if any of them failed.",
+				Optional:    true,
+				ForceNew:    true,
+				Type:        schema.TypeBool,
+			},
+
+			"child_job_id": {
+				Description: "The ID of the job launched by the periodic force.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"eval_id": {
+				Description: "The ID of the evaluation created by the periodic force.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"status": {
+				Description: "The status of the launched job.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"exit_codes": {
+				Description: "The exit code of the last run of each task of the launched job, by \"<group>.<task>\".",
+				Computed:    true,
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+			},
+		},
+	}
+}
+
+func resourceJobPeriodicForceCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(ProviderConfig).client
+
+	jobID := d.Get("job_id").(string)
+	namespace := d.Get("namespace").(string)
+
+	log.Printf("[DEBUG] Forcing launch of periodic job %q in namespace %q", jobID, namespace)
+	evalID, _, err := client.Jobs().PeriodicForce(jobID, &api.WriteOptions{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("error forcing launch of periodic job %q: %s", jobID, err)
+	}
+
+	// The evaluation created by the periodic force is the one of the child
+	// job, which has an ID derived from the launch time.
+	eval, _, err := client.Evaluations().Info(evalID, &api.QueryOptions{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("error reading evaluation %q: %s", evalID, err)
+	}
+	log.Printf("[DEBUG] Launched periodic job %q as %q", jobID, eval.JobID)
+
+	d.SetId(eval.JobID)
+	d.Set("child_job_id", eval.JobID)
+	d.Set("eval_id", evalID)
+
+	if d.Get("wait_for_completion").(bool) {
+		log.Printf("[DEBUG] Waiting for completion of job %q", eval.JobID)
+		err := monitorBatchJob(client, d.Timeout(schema.TimeoutCreate), evalID, eval.JobID, namespace, 0)
+		if err != nil {
+			// read the final status of the job before reporting the error,
+			// the resource is tainted and can be launched again
+			if readErr := resourceJobPeriodicForceRead(d, meta); readErr != nil {
+				log.Printf("[WARN] failed to read job %q: %s", eval.JobID, readErr)
+			}
+			return fmt.Errorf("error waiting for job %q to complete: %s", eval.JobID, err)
+		}
+	}
+
+	return resourceJobPeriodicForceRead(d, meta)
+}
+
+// resourceJobPeriodicForceDelete only removes the launched job from the
+// state: it runs to completion and is then garbage collected by Nomad.
+func resourceJobPeriodicForceDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[DEBUG] Removing launched job %q from the state", d.Id())
+	d.SetId("")
+	return nil
+}
+
+func resourceJobPeriodicForceRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(ProviderConfig).client
+
+	log.Printf("[DEBUG] Reading launched job %q", d.Id())
+	if err := readChildJob(d, client, d.Id()); err != nil {
+		return err
+	}
+	d.Set("child_job_id", d.Id())
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package nomad
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	r "github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestResourceJobPeriodicForce_basic(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJobPeriodicForce_config("1"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttrSet("nomad_job_periodic_force.test", "eval_id"),
+					r.TestCheckResourceAttr("nomad_job_periodic_force.test", "status", "dead"),
+					r.TestCheckResourceAttr("nomad_job_periodic_force.test", "exit_codes.foo.foo", "0"),
+					testResourceJobPeriodicForce_checkChild("nomad_job_periodic_force.test"),
+				),
+			},
+			// changing the triggers launches the job again
+			{
+				Config: testResourceJobPeriodicForce_config("2"),
+				Check:  testResourceJobPeriodicForce_checkChild("nomad_job_periodic_force.test"),
+			},
+		},
+		CheckDestroy: testResourceJob_forceDestroyWithPurge("periodic-force-test", "default"),
+	})
+}
+
+func testResourceJobPeriodicForce_checkChild(name string) r.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("%s not found in state", name)
+		}
+
+		id := rs.Primary.Attributes["child_job_id"]
+		if !strings.HasPrefix(id, "periodic-force-test/periodic-") {
+			return fmt.Errorf("unexpected child job ID %q", id)
+		}
+
+		client := testProvider.Meta().(ProviderConfig).client
+		job, _, err := client.Jobs().Info(id, nil)
+		if err != nil {
+			return fmt.Errorf("error reading child job: %s", err)
+		}
+		if *job.ParentID != "periodic-force-test" {
+			return fmt.Errorf("expected parent %q, got %q", "periodic-force-test", *job.ParentID)
+		}
+		return nil
+	}
+}
+
+func testResourceJobPeriodicForce_config(version string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "periodic" {
+	jobspec = <<EOT
+		job "periodic-force-test" {
+			datacenters = ["dc1"]
+			type = "batch"
+			periodic {
+				cron             = "0 0 1 1 *"
+				prohibit_overlap = true
+			}
+			group "foo" {
+				task "foo" {
+					driver = "raw_exec"
+					config {
+						command = "/bin/sleep"
+						args    = ["1"]
+					}
+					resources {
+						cpu = 100
+						memory = 10
+					}
+				}
+			}
+		}
+	EOT
+}
+
+resource "nomad_job_periodic_force" "test" {
+	job_id              = nomad_job.periodic.id
+	wait_for_completion = true
+
+	triggers = {
+		version = "%s"
+	}
+}
+`, version)
+}
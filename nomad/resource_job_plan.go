@@ -0,0 +1,247 @@
+package nomad
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// Diff types returned by the Nomad job plan endpoint.
+const (
+	diffTypeNone    = "None"
+	diffTypeAdded   = "Added"
+	diffTypeDeleted = "Deleted"
+	diffTypeEdited  = "Edited"
+)
+
+// Disruptive update types reported by the scheduler for each task group, as
+// defined in nomad/structs.
+const (
+	updateTypeDestroy           = "destroy"
+	updateTypeMigrate           = "migrate"
+	updateTypeDestructiveUpdate = "create/destroy update"
+)
+
+// formatJobPlan renders the diff and placement annotations of a job plan in
+// a format similar to the output of `nomad job plan`.
+func formatJobPlan(resp *api.JobPlanResponse) string {
+	if resp == nil || resp.Diff == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(formatJobDiff(resp.Diff))
+
+	if resp.Annotations != nil && len(resp.Annotations.PreemptedAllocs) > 0 {
+		fmt.Fprintf(&b, "\nPreemptions: %d allocation(s)", len(resp.Annotations.PreemptedAllocs))
+	}
+
+	return b.String()
+}
+
+// formatJobDiff renders a job diff in a format similar to the output of
+// `nomad job plan`.
+func formatJobDiff(diff *api.JobDiff) string {
+	if diff == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Job: %q\n", diffPrefix(diff.Type), diff.ID)
+	formatFieldDiffs(&b, diff.Fields, 1)
+	formatObjectDiffs(&b, diff.Objects, 1)
+
+	for _, tg := range diff.TaskGroups {
+		fmt.Fprintf(&b, "%s Task Group: %q", diffPrefix(tg.Type), tg.Name)
+		if updates := formatTaskGroupUpdates(tg.Updates); updates != "" {
+			fmt.Fprintf(&b, " (%s)", updates)
+		}
+		b.WriteString("\n")
+		formatFieldDiffs(&b, tg.Fields, 1)
+		formatObjectDiffs(&b, tg.Objects, 1)
+
+		for _, task := range tg.Tasks {
+			fmt.Fprintf(&b, "  %s Task: %q", diffPrefix(task.Type), task.Name)
+			if len(task.Annotations) > 0 {
+				fmt.Fprintf(&b, " (%s)", strings.Join(task.Annotations, ", "))
+			}
+			b.WriteString("\n")
+			formatFieldDiffs(&b, task.Fields, 2)
+			formatObjectDiffs(&b, task.Objects, 2)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func formatFieldDiffs(b *strings.Builder, fields []*api.FieldDiff, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, f := range fields {
+		if f.Type == diffTypeNone {
+			continue
+		}
+
+		switch f.Type {
+		case diffTypeAdded:
+			fmt.Fprintf(b, "%s%s %s: %q", indent, diffPrefix(f.Type), f.Name, f.New)
+		case diffTypeDeleted:
+			fmt.Fprintf(b, "%s%s %s: %q", indent, diffPrefix(f.Type), f.Name, f.Old)
+		default:
+			fmt.Fprintf(b, "%s%s %s: %q => %q", indent, diffPrefix(f.Type), f.Name, f.Old, f.New)
+		}
+		if len(f.Annotations) > 0 {
+			fmt.Fprintf(b, " (%s)", strings.Join(f.Annotations, ", "))
+		}
+		b.WriteString("\n")
+	}
+}
+
+func formatObjectDiffs(b *strings.Builder, objects []*api.ObjectDiff, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, o := range objects {
+		if o.Type == diffTypeNone {
+			continue
+		}
+
+		fmt.Fprintf(b, "%s%s %s {\n", indent, diffPrefix(o.Type), o.Name)
+		formatFieldDiffs(b, o.Fields, depth+1)
+		formatObjectDiffs(b, o.Objects, depth+1)
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// formatTaskGroupUpdates renders the scheduler updates for a task group, for
+// example "1 create/destroy update, 2 ignore".
+func formatTaskGroupUpdates(updates map[string]uint64) string {
+	keys := make([]string, 0, len(updates))
+	for k, v := range updates {
+		if v == 0 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%d %s", updates[k], k))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func diffPrefix(diffType string) string {
+	switch diffType {
+	case diffTypeAdded:
+		return "+"
+	case diffTypeDeleted:
+		return "-"
+	case diffTypeEdited:
+		return "+/-"
+	default:
+		return " "
+	}
+}
+
+// jobPlanWarnings returns the warnings of a job plan and the parts of it
+// that will disrupt running allocations. Providers cannot report warnings in
+// a plan, so they are set in the plan_warnings attribute.
+func jobPlanWarnings(resp *api.JobPlanResponse) []string {
+	if resp == nil {
+		return nil
+	}
+
+	var warnings []string
+	if w := strings.TrimSpace(resp.Warnings); w != "" {
+		warnings = append(warnings, w)
+	}
+
+	if resp.Diff != nil {
+		for _, tg := range resp.Diff.TaskGroups {
+			for _, u := range []string{updateTypeDestructiveUpdate, updateTypeDestroy, updateTypeMigrate} {
+				if n := tg.Updates[u]; n > 0 {
+					warnings = append(warnings, fmt.Sprintf("task group %q will have %d %s", tg.Name, n, u))
+				}
+			}
+		}
+	}
+	if resp.Annotations != nil && len(resp.Annotations.PreemptedAllocs) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d allocation(s) will be preempted",
+			len(resp.Annotations.PreemptedAllocs)))
+	}
+
+	names := make([]string, 0, len(resp.FailedTGAllocs))
+	for name := range resp.FailedTGAllocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if metric := resp.FailedTGAllocs[name]; metric != nil {
+			warnings = append(warnings, fmt.Sprintf("task group %q failed to place %d allocation(s)",
+				name, metric.CoalescedFailures+1))
+		}
+	}
+
+	return warnings
+}
+
+// formatPlacementFailures renders the allocation metrics of the task groups
+// that the scheduler failed to place, in a format similar to the output of
+// `nomad job plan`.
+func formatPlacementFailures(failed map[string]*api.AllocationMetric) string {
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		metric := failed[name]
+		if metric == nil {
+			continue
+		}
+
+		fmt.Fprintf(&b, "task group %q (failed to place %d allocation(s)):\n",
+			name, metric.CoalescedFailures+1)
+
+		if metric.NodesEvaluated == 0 {
+			b.WriteString("  * No nodes were eligible for evaluation\n")
+		} else {
+			fmt.Fprintf(&b, "  * %d node(s) evaluated, %d filtered, %d exhausted\n",
+				metric.NodesEvaluated, metric.NodesFiltered, metric.NodesExhausted)
+		}
+		for _, dc := range sortedMetricKeys(metric.NodesAvailable) {
+			if metric.NodesAvailable[dc] == 0 {
+				fmt.Fprintf(&b, "  * No nodes are available in datacenter %q\n", dc)
+			}
+		}
+		for _, class := range sortedMetricKeys(metric.ClassFiltered) {
+			fmt.Fprintf(&b, "  * Class %q: %d node(s) excluded by filter\n", class, metric.ClassFiltered[class])
+		}
+		for _, cs := range sortedMetricKeys(metric.ConstraintFiltered) {
+			fmt.Fprintf(&b, "  * Constraint %q: %d node(s) excluded by filter\n", cs, metric.ConstraintFiltered[cs])
+		}
+		for _, class := range sortedMetricKeys(metric.ClassExhausted) {
+			fmt.Fprintf(&b, "  * Class %q exhausted on %d node(s)\n", class, metric.ClassExhausted[class])
+		}
+		for _, dim := range sortedMetricKeys(metric.DimensionExhausted) {
+			fmt.Fprintf(&b, "  * Dimension %q exhausted on %d node(s)\n", dim, metric.DimensionExhausted[dim])
+		}
+		for _, quota := range metric.QuotaExhausted {
+			fmt.Fprintf(&b, "  * Quota limit hit %q\n", quota)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func sortedMetricKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
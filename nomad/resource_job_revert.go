@@ -0,0 +1,138 @@
+package nomad
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func resourceJobRevert() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceJobRevertCreate,
+		Delete: resourceJobRevertDelete,
+		Read:   resourceJobRevertRead,
+
+		Schema: map[string]*schema.Schema{
+			"job_id": {
+				Description: "The ID of the job to revert.",
+				Required:    true,
+				ForceNew:    true,
+				Type:        schema.TypeString,
+			},
+
+			"namespace": {
+				Description: "The namespace of the job.",
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+				Type:        schema.TypeString,
+			},
+
+			"version": {
+				Description:  "The version of the job to revert to.",
+				Required:     true,
+				ForceNew:     true,
+				Type:         schema.TypeInt,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+
+			"enforce_prior_version": {
+				Description:  "If set, the revert fails unless the current version of the job is this version.",
+				Optional:     true,
+				ForceNew:     true,
+				Type:         schema.TypeInt,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+
+			"vault_token": {
+				Description: "The Vault token used to revert the job, overriding the one of the provider.",
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Type:        schema.TypeString,
+			},
+
+			"consul_token": {
+				Description: "The Consul token used to revert the job, overriding the one of the provider.",
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Type:        schema.TypeString,
+			},
+
+			"triggers": {
+				Description: "Arbitrary values that, when changed, revert the job again.",
+				Optional:    true,
+				ForceNew:    true,
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"eval_id": {
+				Description: "The ID of the evaluation created by the revert.",
+				Computed:    true,
+				Type:        schema.TypeString,
+			},
+
+			"job_modify_index": {
+				Description: "The modify index of the job after the revert.",
+				Computed:    true,
+				Type:        schema.TypeString, // it's an int64, so won't fit in our TypeInt
+			},
+		},
+	}
+}
+
+func resourceJobRevertCreate(d *schema.ResourceData, meta interface{}) error {
+	providerConfig := meta.(ProviderConfig)
+	client := providerConfig.client
+
+	jobID := d.Get("job_id").(string)
+	namespace := d.Get("namespace").(string)
+	version := uint64(d.Get("version").(int))
+
+	var enforcePriorVersion *uint64
+	if v, ok := d.GetOkExists("enforce_prior_version"); ok {
+		prior := uint64(v.(int))
+		enforcePriorVersion = &prior
+	}
+
+	vaultToken, consulToken, err := jobTokens(d, providerConfig)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Reverting job %q in namespace %q to version %d", jobID, namespace, version)
+	resp, err := revertJob(client, jobID, version, enforcePriorVersion, &api.WriteOptions{
+		Namespace: namespace,
+	}, stringValue(consulToken), stringValue(vaultToken))
+	if err != nil {
+		return fmt.Errorf("error reverting job %q to version %d: %s", jobID, version, err)
+	}
+	log.Printf("[DEBUG] Reverted job %q to version %d", jobID, version)
+
+	d.SetId(fmt.Sprintf("%s/%s@%d", namespace, jobID, resp.JobModifyIndex))
+	d.Set("eval_id", resp.EvalID)
+	d.Set("job_modify_index", strconv.FormatUint(resp.JobModifyIndex, 10))
+
+	return resourceJobRevertRead(d, meta)
+}
+
+// resourceJobRevertDelete only removes the revert from the state: the job is
+// not reverted back, it is managed by the nomad_job resource or the Nomad
+// CLI.
+func resourceJobRevertDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[DEBUG] Removing revert %q from the state", d.Id())
+	d.SetId("")
+	return nil
+}
+
+// resourceJobRevertRead is a no-op: a revert is a one-off operation, and the
+// subsequent changes to the job do not make it drift.
+func resourceJobRevertRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
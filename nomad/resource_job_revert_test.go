@@ -0,0 +1,148 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	r "github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceJobRevertCreate_tokens(t *testing.T) {
+	var revert api.JobRevertRequest
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo/revert": func(r *http.Request) interface{} {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&revert))
+			return &api.JobRegisterResponse{EvalID: "eval-1", JobModifyIndex: 42}
+		},
+	})
+
+	providerConsulToken := "provider-consul"
+	providerConfig := ProviderConfig{
+		client:      client,
+		vaultToken:  staticTokenSource("provider-vault"),
+		consulToken: &providerConsulToken,
+	}
+
+	// The provider tokens are used by default
+	d := resourceJobRevert().TestResourceData()
+	require.NoError(t, d.Set("job_id", "foo"))
+	require.NoError(t, d.Set("namespace", "default"))
+	require.NoError(t, d.Set("version", 1))
+	require.NoError(t, resourceJobRevertCreate(d, providerConfig))
+	require.Equal(t, "provider-consul", revert.ConsulToken)
+	require.Equal(t, "provider-vault", revert.VaultToken)
+	require.Equal(t, "default/foo@42", d.Id())
+
+	// and the ones of the resource take precedence
+	require.NoError(t, d.Set("consul_token", "revert-consul"))
+	require.NoError(t, d.Set("vault_token", "revert-vault"))
+	require.NoError(t, resourceJobRevertCreate(d, providerConfig))
+	require.Equal(t, "revert-consul", revert.ConsulToken)
+	require.Equal(t, "revert-vault", revert.VaultToken)
+}
+
+func TestResourceJobRevert_negativeVersion(t *testing.T) {
+	for _, field := range []string{"version", "enforce_prior_version"} {
+		_, errs := resourceJobRevert().Schema[field].ValidateFunc(-1, field)
+		require.Len(t, errs, 1, field)
+	}
+}
+
+func TestResourceJobRevert_basic(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJobRevert_jobConfig("1"),
+			},
+			{
+				Config: testResourceJobRevert_jobConfig("2") + testResourceJobRevert_revertConfig(1),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttrSet("nomad_job_revert.test", "eval_id"),
+					testResourceJobRevert_checkMeta("1"),
+				),
+			},
+		},
+		CheckDestroy: testResourceJob_forceDestroyWithPurge("revert-test", "default"),
+	})
+}
+
+func TestResourceJobRevert_enforcePriorVersion(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJobRevert_jobConfig("1"),
+			},
+			{
+				Config:      testResourceJobRevert_jobConfig("2") + testResourceJobRevert_revertConfig(5),
+				ExpectError: regexp.MustCompile("enforcing version 5"),
+			},
+		},
+		CheckDestroy: testResourceJob_forceDestroyWithPurge("revert-test", "default"),
+	})
+}
+
+func testResourceJobRevert_checkMeta(want string) r.TestCheckFunc {
+	return func(*terraform.State) error {
+		client := testProvider.Meta().(ProviderConfig).client
+		job, _, err := client.Jobs().Info("revert-test", nil)
+		if err != nil {
+			return fmt.Errorf("error reading job: %s", err)
+		}
+		if got := job.Meta["version"]; got != want {
+			return fmt.Errorf("expected meta version %q, got %q", want, got)
+		}
+		return nil
+	}
+}
+
+func testResourceJobRevert_jobConfig(version string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "test" {
+	detect_drift = false
+	jobspec = <<EOT
+job "revert-test" {
+	datacenters = ["dc1"]
+	type = "batch"
+	meta {
+		version = "%s"
+	}
+	group "foo" {
+		task "foo" {
+			driver = "raw_exec"
+			config {
+				command = "/bin/sleep"
+				args    = ["1"]
+			}
+			resources {
+				cpu = 100
+				memory = 10
+			}
+		}
+	}
+}
+EOT
+}
+`, version)
+}
+
+func testResourceJobRevert_revertConfig(priorVersion int) string {
+	return fmt.Sprintf(`
+resource "nomad_job_revert" "test" {
+	job_id                = nomad_job.test.id
+	version               = 0
+	enforce_prior_version = %d
+
+	depends_on = [nomad_job.test]
+}
+`, priorVersion)
+}
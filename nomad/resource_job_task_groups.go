@@ -0,0 +1,841 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// jobTaskGroupsSchema returns the schema of the computed task_groups
+// attribute, shared by the nomad_job resource and data source.
+func jobTaskGroupsSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"count": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"meta": {
+					Computed: true,
+					Type:     schema.TypeMap,
+				},
+				"constraints":       jobConstraintsSchema(),
+				"networks":          jobNetworksSchema(),
+				"services":          jobServicesSchema(),
+				"update_strategy":   jobUpdateStrategySchema(),
+				"restart_policy":    jobRestartPolicySchema(),
+				"reschedule_policy": jobReschedulePolicySchema(),
+				"ephemeral_disk":    jobEphemeralDiskSchema(),
+				"migrate_strategy":  jobMigrateStrategySchema(),
+				"task": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"name": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"driver": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"meta": {
+								Computed: true,
+								Type:     schema.TypeMap,
+							},
+							"config": {
+								Computed: true,
+								Type:     schema.TypeMap,
+								Elem:     &schema.Schema{Type: schema.TypeString},
+							},
+							"env": {
+								Computed: true,
+								Type:     schema.TypeMap,
+								Elem:     &schema.Schema{Type: schema.TypeString},
+							},
+							"constraints": jobConstraintsSchema(),
+							"services":    jobServicesSchema(),
+							"resources": {
+								Computed: true,
+								Type:     schema.TypeList,
+								Elem: &schema.Resource{
+									Schema: map[string]*schema.Schema{
+										"cpu": {
+											Computed: true,
+											Type:     schema.TypeInt,
+										},
+										"memory": {
+											Computed: true,
+											Type:     schema.TypeInt,
+										},
+										"networks": jobNetworksSchema(),
+									},
+								},
+							},
+							"artifacts": {
+								Computed: true,
+								Type:     schema.TypeList,
+								Elem: &schema.Resource{
+									Schema: map[string]*schema.Schema{
+										"source": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"destination": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"mode": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"options": {
+											Computed: true,
+											Type:     schema.TypeMap,
+											Elem:     &schema.Schema{Type: schema.TypeString},
+										},
+									},
+								},
+							},
+							"templates": {
+								Computed: true,
+								Type:     schema.TypeList,
+								Elem: &schema.Resource{
+									Schema: map[string]*schema.Schema{
+										"source": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"destination": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"data": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"change_mode": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"change_signal": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"perms": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"env": {
+											Computed: true,
+											Type:     schema.TypeBool,
+										},
+									},
+								},
+							},
+							"volume_mounts": {
+								Computed: true,
+								Type:     schema.TypeList,
+								Elem: &schema.Resource{
+									Schema: map[string]*schema.Schema{
+										"volume": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"destination": {
+											Computed: true,
+											Type:     schema.TypeString,
+										},
+										"read_only": {
+											Computed: true,
+											Type:     schema.TypeBool,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				"volumes": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"name": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"type": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"read_only": {
+								Computed: true,
+								Type:     schema.TypeBool,
+							},
+							"source": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func jobConstraintsSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ltarget": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"rtarget": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"operand": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func jobNetworksSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"mode": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"mbits": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"ports": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"label": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"static": {
+								Computed: true,
+								Type:     schema.TypeBool,
+							},
+							"value": {
+								Computed: true,
+								Type:     schema.TypeInt,
+							},
+							"to": {
+								Computed: true,
+								Type:     schema.TypeInt,
+							},
+							"host_network": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func jobServicesSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"port_label": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"address_mode": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"task": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"tags": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"canary_tags": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"meta": {
+					Computed: true,
+					Type:     schema.TypeMap,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"checks": {
+					Computed: true,
+					Type:     schema.TypeList,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"name": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"type": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"path": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"protocol": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"port_label": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"interval": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+							"timeout": {
+								Computed: true,
+								Type:     schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func jobUpdateStrategySchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"max_parallel": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"health_check": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"min_healthy_time": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"healthy_deadline": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"progress_deadline": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"canary": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"auto_revert": {
+					Computed: true,
+					Type:     schema.TypeBool,
+				},
+				"auto_promote": {
+					Computed: true,
+					Type:     schema.TypeBool,
+				},
+			},
+		},
+	}
+}
+
+func jobRestartPolicySchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"attempts": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"interval": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"delay": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"mode": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func jobReschedulePolicySchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"attempts": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"interval": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"delay": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"delay_function": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"max_delay": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"unlimited": {
+					Computed: true,
+					Type:     schema.TypeBool,
+				},
+			},
+		},
+	}
+}
+
+func jobEphemeralDiskSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"sticky": {
+					Computed: true,
+					Type:     schema.TypeBool,
+				},
+				"migrate": {
+					Computed: true,
+					Type:     schema.TypeBool,
+				},
+				"size_mb": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+			},
+		},
+	}
+}
+
+func jobMigrateStrategySchema() *schema.Schema {
+	return &schema.Schema{
+		Computed: true,
+		Type:     schema.TypeList,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"max_parallel": {
+					Computed: true,
+					Type:     schema.TypeInt,
+				},
+				"health_check": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"min_healthy_time": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+				"healthy_deadline": {
+					Computed: true,
+					Type:     schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func jobTaskGroupsRaw(tgs []*api.TaskGroup) []interface{} {
+	ret := make([]interface{}, 0, len(tgs))
+
+	for _, tg := range tgs {
+		tgM := make(map[string]interface{})
+
+		if tg.Name != nil {
+			tgM["name"] = *tg.Name
+		} else {
+			tgM["name"] = ""
+		}
+		if tg.Count != nil {
+			tgM["count"] = *tg.Count
+		} else {
+			tgM["count"] = 1
+		}
+		if tg.Meta != nil {
+			tgM["meta"] = tg.Meta
+		} else {
+			tgM["meta"] = make(map[string]interface{})
+		}
+		tgM["constraints"] = jobConstraintsRaw(tg.Constraints)
+		tgM["networks"] = jobNetworksRaw(tg.Networks)
+		tgM["services"] = jobServicesRaw(tg.Services)
+		tgM["update_strategy"] = jobUpdateStrategyRaw(tg.Update)
+		tgM["restart_policy"] = jobRestartPolicyRaw(tg.RestartPolicy)
+		tgM["reschedule_policy"] = jobReschedulePolicyRaw(tg.ReschedulePolicy)
+		tgM["ephemeral_disk"] = jobEphemeralDiskRaw(tg.EphemeralDisk)
+		tgM["migrate_strategy"] = jobMigrateStrategyRaw(tg.Migrate)
+
+		tasksI := make([]interface{}, 0, len(tg.Tasks))
+		for _, task := range tg.Tasks {
+			taskM := make(map[string]interface{})
+
+			taskM["name"] = task.Name
+			taskM["driver"] = task.Driver
+			if task.Meta != nil {
+				taskM["meta"] = task.Meta
+			} else {
+				taskM["meta"] = make(map[string]interface{})
+			}
+			taskM["config"] = jobTaskConfigRaw(task.Config)
+			if task.Env != nil {
+				taskM["env"] = task.Env
+			} else {
+				taskM["env"] = make(map[string]interface{})
+			}
+			taskM["constraints"] = jobConstraintsRaw(task.Constraints)
+			taskM["services"] = jobServicesRaw(task.Services)
+
+			resourcesI := make([]interface{}, 0, 1)
+			if r := task.Resources; r != nil {
+				resourceM := make(map[string]interface{})
+				if r.CPU != nil {
+					resourceM["cpu"] = *r.CPU
+				}
+				if r.MemoryMB != nil {
+					resourceM["memory"] = *r.MemoryMB
+				}
+				resourceM["networks"] = jobNetworksRaw(r.Networks)
+				resourcesI = append(resourcesI, resourceM)
+			}
+			taskM["resources"] = resourcesI
+
+			artifactsI := make([]interface{}, 0, len(task.Artifacts))
+			for _, a := range task.Artifacts {
+				artifactM := make(map[string]interface{})
+
+				artifactM["source"] = stringValue(a.GetterSource)
+				artifactM["destination"] = stringValue(a.RelativeDest)
+				artifactM["mode"] = stringValue(a.GetterMode)
+				if a.GetterOptions != nil {
+					artifactM["options"] = a.GetterOptions
+				} else {
+					artifactM["options"] = make(map[string]interface{})
+				}
+
+				artifactsI = append(artifactsI, artifactM)
+			}
+			taskM["artifacts"] = artifactsI
+
+			templatesI := make([]interface{}, 0, len(task.Templates))
+			for _, t := range task.Templates {
+				templateM := make(map[string]interface{})
+
+				templateM["source"] = stringValue(t.SourcePath)
+				templateM["destination"] = stringValue(t.DestPath)
+				templateM["data"] = stringValue(t.EmbeddedTmpl)
+				templateM["change_mode"] = stringValue(t.ChangeMode)
+				templateM["change_signal"] = stringValue(t.ChangeSignal)
+				templateM["perms"] = stringValue(t.Perms)
+				templateM["env"] = t.Envvars != nil && *t.Envvars
+
+				templatesI = append(templatesI, templateM)
+			}
+			taskM["templates"] = templatesI
+
+			volumeMountsI := make([]interface{}, 0, len(task.VolumeMounts))
+			for _, vm := range task.VolumeMounts {
+				volumeMountM := make(map[string]interface{})
+
+				volumeMountM["volume"] = vm.Volume
+				volumeMountM["destination"] = vm.Destination
+				volumeMountM["read_only"] = vm.ReadOnly
+
+				volumeMountsI = append(volumeMountsI, volumeMountM)
+			}
+			taskM["volume_mounts"] = volumeMountsI
+
+			tasksI = append(tasksI, taskM)
+		}
+		tgM["task"] = tasksI
+
+		volumesI := make([]interface{}, 0, len(tg.Volumes))
+		for _, v := range tg.Volumes {
+			volumeM := make(map[string]interface{})
+
+			volumeM["name"] = v.Name
+			volumeM["type"] = v.Type
+			volumeM["read_only"] = v.ReadOnly
+			volumeM["source"] = v.Source
+
+			volumesI = append(volumesI, volumeM)
+		}
+		sort.Slice(volumesI, func(i, j int) bool {
+			return volumesI[i].(map[string]interface{})["name"].(string) <
+				volumesI[j].(map[string]interface{})["name"].(string)
+		})
+
+		tgM["volumes"] = volumesI
+
+		ret = append(ret, tgM)
+	}
+
+	return ret
+}
+
+func jobConstraintsRaw(constraints []*api.Constraint) []interface{} {
+	ret := make([]interface{}, 0, len(constraints))
+	for _, c := range constraints {
+		ret = append(ret, map[string]interface{}{
+			"ltarget": c.LTarget,
+			"rtarget": c.RTarget,
+			"operand": c.Operand,
+		})
+	}
+	return ret
+}
+
+func jobNetworksRaw(networks []*api.NetworkResource) []interface{} {
+	ret := make([]interface{}, 0, len(networks))
+	for _, n := range networks {
+		networkM := make(map[string]interface{})
+
+		networkM["mode"] = n.Mode
+		if n.MBits != nil {
+			networkM["mbits"] = *n.MBits
+		}
+
+		portsI := make([]interface{}, 0, len(n.ReservedPorts)+len(n.DynamicPorts))
+		for _, p := range n.ReservedPorts {
+			portsI = append(portsI, jobPortRaw(p, true))
+		}
+		for _, p := range n.DynamicPorts {
+			portsI = append(portsI, jobPortRaw(p, false))
+		}
+		networkM["ports"] = portsI
+
+		ret = append(ret, networkM)
+	}
+	return ret
+}
+
+func jobPortRaw(p api.Port, static bool) map[string]interface{} {
+	return map[string]interface{}{
+		"label":        p.Label,
+		"static":       static,
+		"value":        p.Value,
+		"to":           p.To,
+		"host_network": p.HostNetwork,
+	}
+}
+
+func jobServicesRaw(services []*api.Service) []interface{} {
+	ret := make([]interface{}, 0, len(services))
+	for _, s := range services {
+		serviceM := make(map[string]interface{})
+
+		serviceM["name"] = s.Name
+		serviceM["port_label"] = s.PortLabel
+		serviceM["address_mode"] = s.AddressMode
+		serviceM["task"] = s.TaskName
+		serviceM["tags"] = s.Tags
+		serviceM["canary_tags"] = s.CanaryTags
+		if s.Meta != nil {
+			serviceM["meta"] = s.Meta
+		} else {
+			serviceM["meta"] = make(map[string]interface{})
+		}
+
+		checksI := make([]interface{}, 0, len(s.Checks))
+		for _, c := range s.Checks {
+			checksI = append(checksI, map[string]interface{}{
+				"name":       c.Name,
+				"type":       c.Type,
+				"path":       c.Path,
+				"protocol":   c.Protocol,
+				"port_label": c.PortLabel,
+				"interval":   durationString(&c.Interval),
+				"timeout":    durationString(&c.Timeout),
+			})
+		}
+		serviceM["checks"] = checksI
+
+		ret = append(ret, serviceM)
+	}
+	return ret
+}
+
+func jobUpdateStrategyRaw(u *api.UpdateStrategy) []interface{} {
+	if u == nil {
+		return []interface{}{}
+	}
+
+	updateM := make(map[string]interface{})
+	if u.MaxParallel != nil {
+		updateM["max_parallel"] = *u.MaxParallel
+	}
+	updateM["health_check"] = stringValue(u.HealthCheck)
+	updateM["min_healthy_time"] = durationString(u.MinHealthyTime)
+	updateM["healthy_deadline"] = durationString(u.HealthyDeadline)
+	updateM["progress_deadline"] = durationString(u.ProgressDeadline)
+	if u.Canary != nil {
+		updateM["canary"] = *u.Canary
+	}
+	updateM["auto_revert"] = u.AutoRevert != nil && *u.AutoRevert
+	updateM["auto_promote"] = u.AutoPromote != nil && *u.AutoPromote
+
+	return []interface{}{updateM}
+}
+
+func jobRestartPolicyRaw(r *api.RestartPolicy) []interface{} {
+	if r == nil {
+		return []interface{}{}
+	}
+
+	restartM := make(map[string]interface{})
+	if r.Attempts != nil {
+		restartM["attempts"] = *r.Attempts
+	}
+	restartM["interval"] = durationString(r.Interval)
+	restartM["delay"] = durationString(r.Delay)
+	restartM["mode"] = stringValue(r.Mode)
+
+	return []interface{}{restartM}
+}
+
+func jobReschedulePolicyRaw(r *api.ReschedulePolicy) []interface{} {
+	if r == nil {
+		return []interface{}{}
+	}
+
+	rescheduleM := make(map[string]interface{})
+	if r.Attempts != nil {
+		rescheduleM["attempts"] = *r.Attempts
+	}
+	rescheduleM["interval"] = durationString(r.Interval)
+	rescheduleM["delay"] = durationString(r.Delay)
+	rescheduleM["delay_function"] = stringValue(r.DelayFunction)
+	rescheduleM["max_delay"] = durationString(r.MaxDelay)
+	rescheduleM["unlimited"] = r.Unlimited != nil && *r.Unlimited
+
+	return []interface{}{rescheduleM}
+}
+
+func jobEphemeralDiskRaw(e *api.EphemeralDisk) []interface{} {
+	if e == nil {
+		return []interface{}{}
+	}
+
+	diskM := make(map[string]interface{})
+	diskM["sticky"] = e.Sticky != nil && *e.Sticky
+	diskM["migrate"] = e.Migrate != nil && *e.Migrate
+	if e.SizeMB != nil {
+		diskM["size_mb"] = *e.SizeMB
+	}
+
+	return []interface{}{diskM}
+}
+
+func jobMigrateStrategyRaw(m *api.MigrateStrategy) []interface{} {
+	if m == nil {
+		return []interface{}{}
+	}
+
+	migrateM := make(map[string]interface{})
+	if m.MaxParallel != nil {
+		migrateM["max_parallel"] = *m.MaxParallel
+	}
+	migrateM["health_check"] = stringValue(m.HealthCheck)
+	migrateM["min_healthy_time"] = durationString(m.MinHealthyTime)
+	migrateM["healthy_deadline"] = durationString(m.HealthyDeadline)
+
+	return []interface{}{migrateM}
+}
+
+// jobTaskConfigRaw flattens the driver configuration of a task to a map of
+// strings: strings are kept as is, and other values are encoded as JSON.
+func jobTaskConfigRaw(config map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		if s, ok := v.(string); ok {
+			ret[k] = s
+			continue
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			ret[k] = fmt.Sprintf("%v", v)
+			continue
+		}
+		ret[k] = string(b)
+	}
+	return ret
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func durationString(d *time.Duration) string {
+	if d == nil || *d == 0 {
+		return ""
+	}
+	return d.String()
+}
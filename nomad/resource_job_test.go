@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -15,12 +17,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/api"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 
 	r "github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 
 	"github.com/hashicorp/terraform-provider-nomad/nomad/core/helper"
@@ -41,6 +45,30 @@ func TestResourceJob_basic(t *testing.T) {
 	})
 }
 
+func TestResourceJob_import(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_initialConfig,
+				Check:  testResourceJob_initialCheck(t),
+			},
+			{
+				ResourceName:      "nomad_job.test",
+				ImportState:       true,
+				ImportStateId:     "default/foo",
+				ImportStateVerify: true,
+				ImportStateVerifyIgnore: []string{
+					"jobspec", "json", "plan_diff", "deployment_id", "deployment_status",
+				},
+			},
+		},
+
+		CheckDestroy: testResourceJob_checkDestroy("foo"),
+	})
+}
+
 func TestResourceJob_service(t *testing.T) {
 	r.Test(t, r.TestCase{
 		Providers: testProviders,
@@ -131,6 +159,39 @@ func TestResourceJob_scalingPolicy(t *testing.T) {
 
 }
 
+func TestResourceJob_preserveCounts(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t); testCheckMinVersion(t, "0.11.0-beta1") },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_preserveCountsConfig,
+				Check:  r.TestCheckResourceAttr("nomad_job.test", "task_groups.0.count", "1"),
+			},
+
+			// Scaling the group outside of Terraform should not cause a diff.
+			{
+				PreConfig: func() {
+					client := testProvider.Meta().(ProviderConfig).client
+					count := 2
+					_, _, err := client.Jobs().Scale("foo-preserve-counts", "foo", &count, "autoscaler", false, nil, nil)
+					if err != nil {
+						t.Fatalf("error scaling job: %s", err)
+					}
+				},
+				Config:   testResourceJob_preserveCountsConfig,
+				PlanOnly: true,
+			},
+			{
+				Config: testResourceJob_preserveCountsConfig,
+				Check:  r.TestCheckResourceAttr("nomad_job.test", "task_groups.0.count", "2"),
+			},
+		},
+
+		CheckDestroy: testResourceJob_checkDestroy("foo-preserve-counts"),
+	})
+}
+
 func TestResourceJob_lifecycle(t *testing.T) {
 	r.Test(t, r.TestCase{
 		Providers: testProviders,
@@ -159,6 +220,35 @@ func TestResourceJob_serviceDeploymentInfo(t *testing.T) {
 	})
 }
 
+func TestResourceJob_canaryPromotion(t *testing.T) {
+	resourceName := "nomad_job.canary"
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_canaryConfig("manual", "1"),
+				Check:  r.TestCheckResourceAttr(resourceName, "deployment_status", "successful"),
+			},
+			// canaries are left for manual promotion
+			{
+				Config: testResourceJob_canaryConfig("manual", "2"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr(resourceName, "deployment_status", "running"),
+					r.TestCheckResourceAttr(resourceName, "deployment_status_description",
+						"Deployment is running but requires manual promotion"),
+				),
+			},
+			// canaries are promoted by the provider
+			{
+				Config: testResourceJob_canaryConfig("auto", "3"),
+				Check:  r.TestCheckResourceAttr(resourceName, "deployment_status", "successful"),
+			},
+		},
+		CheckDestroy: testResourceJob_checkDestroy("foo-canary"),
+	})
+}
+
 func TestResourceJob_batchNoDetach(t *testing.T) {
 	resourceName := "nomad_job.batch_no_detach"
 	r.Test(t, r.TestCase{
@@ -178,6 +268,38 @@ func TestResourceJob_batchNoDetach(t *testing.T) {
 	})
 }
 
+func TestResourceJob_batchWaitForCompletion(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_batchWaitForCompletionConfig("foo-batch-complete", "/bin/true"),
+				Check: func(*terraform.State) error {
+					client := testProvider.Meta().(ProviderConfig).client
+					summary, _, err := client.Jobs().Summary("foo-batch-complete", nil)
+					if err != nil {
+						return err
+					}
+					if s := summary.Summary["foo"]; s.Complete != 1 {
+						return fmt.Errorf("expected 1 complete allocation, got %#v", s)
+					}
+					return nil
+				},
+			},
+			{
+				Config:      testResourceJob_batchWaitForCompletionConfig("foo-batch-failed", "/bin/false"),
+				ExpectError: regexp.MustCompile(`allocation\(s\) failed(.|\n)*exit code 1`),
+			},
+		},
+
+		CheckDestroy: r.ComposeTestCheckFunc(
+			testResourceJob_checkDestroy("foo-batch-complete"),
+			testResourceJob_checkDestroy("foo-batch-failed"),
+		),
+	})
+}
+
 func TestResourceJob_serviceWithoutDeployment(t *testing.T) {
 	resourceName := "nomad_job.service"
 	r.Test(t, r.TestCase{
@@ -210,6 +332,25 @@ func TestResourceJob_multiregion(t *testing.T) {
 	})
 }
 
+func TestResourceJob_multiregionNoDetach(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t); testCheckMinVersion(t, "0.12.0-beta1") },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_multiregionNoDetach,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("nomad_job.multiregion", "multiregion_deployments.#", "1"),
+					r.TestCheckResourceAttr("nomad_job.multiregion", "multiregion_deployments.0.region", "global"),
+					r.TestCheckResourceAttrSet("nomad_job.multiregion", "multiregion_deployments.0.deployment_id"),
+					r.TestCheckResourceAttr("nomad_job.multiregion", "multiregion_deployments.0.status", "successful"),
+				),
+			},
+		},
+		CheckDestroy: testResourceJob_checkDestroy("foo-multiregion-nodetach"),
+	})
+}
+
 func TestResourceJob_csiController(t *testing.T) {
 	r.Test(t, r.TestCase{
 		Providers: testProviders,
@@ -310,6 +451,51 @@ func TestResourceJob_refresh(t *testing.T) {
 	})
 }
 
+func TestResourceJob_detectDrift(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_detectDriftConfig,
+				Check: r.ComposeTestCheckFunc(
+					testResourceJob_initialCheck(t),
+					r.TestCheckResourceAttr("nomad_job.test", "drift", ""),
+				),
+			},
+
+			// Modifying the job outside of Terraform should cause a diff.
+			{
+				PreConfig:          testResourceJob_updateMeta(t, "foo"),
+				Config:             testResourceJob_detectDriftConfig,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+
+			// Applying the configuration should revert the change.
+			{
+				Config: testResourceJob_detectDriftConfig,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("nomad_job.test", "drift", ""),
+					func(*terraform.State) error {
+						client := testProvider.Meta().(ProviderConfig).client
+						job, _, err := client.Jobs().Info("foo", nil)
+						if err != nil {
+							return err
+						}
+						if _, ok := job.Meta["drift"]; ok {
+							return fmt.Errorf("job still has the meta added outside of Terraform")
+						}
+						return nil
+					},
+				),
+			},
+		},
+
+		CheckDestroy: testResourceJob_checkDestroy("foo"),
+	})
+}
+
 func TestResourceJob_disableDestroyDeregister(t *testing.T) {
 	r.Test(t, r.TestCase{
 		Providers: testProviders,
@@ -447,6 +633,87 @@ func TestResourceJob_purgeOnDestroy(t *testing.T) {
 	})
 }
 
+func TestResourceJob_destroyModeStop(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			// create the resource
+			{
+				Config: testResourceJob_destroyModeStop,
+				Check:  testResourceJob_initialCheck(t),
+			},
+			// make sure the job is stopped, not purged, and that its
+			// allocations are terminal once destroyed
+			{
+				Destroy: true,
+				Config:  testResourceJob_destroyModeStop,
+				Check: func(s *terraform.State) error {
+					providerConfig := testProvider.Meta().(ProviderConfig)
+					client := providerConfig.client
+					job, _, err := client.Jobs().Info("foo", nil)
+					if err != nil {
+						return err
+					}
+					if job.Stop == nil || !*job.Stop {
+						return fmt.Errorf("job has not been stopped")
+					}
+
+					allocs, _, err := client.Jobs().Allocations("foo", false, nil)
+					if err != nil {
+						return err
+					}
+					for _, a := range allocs {
+						if !allocationIsTerminal(a.ClientStatus) {
+							return fmt.Errorf("allocation %q is %s", a.ID, a.ClientStatus)
+						}
+					}
+					return nil
+				},
+			},
+		},
+		CheckDestroy: r.ComposeTestCheckFunc(
+			testResourceJob_checkDestroy("foo"),
+			testResourceJob_forceDestroyWithPurge("foo", "default"),
+		),
+	})
+}
+
+func TestResourceJob_hcl2(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config: testResourceJob_hcl2Config,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("nomad_job.hcl2", "name", "foo-hcl2"),
+					r.TestCheckResourceAttr("nomad_job.hcl2", "task_groups.0.count", "2"),
+					r.TestCheckResourceAttr("nomad_job.hcl2", "task_groups.0.task.#", "2"),
+					r.TestCheckResourceAttr("nomad_job.hcl2", "task_groups.0.task.0.meta.tasks", "2"),
+				),
+			},
+		},
+
+		CheckDestroy: testResourceJob_checkDestroy("foo-hcl2"),
+	})
+}
+
+func TestResourceJob_failOnPlacementFailure(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		PreCheck:  func() { testAccPreCheck(t) },
+		Steps: []r.TestStep{
+			{
+				Config:      testResourceJob_placementFailureConfig,
+				ExpectError: regexp.MustCompile(`Dimension "memory" exhausted`),
+			},
+		},
+
+		CheckDestroy: testResourceJob_checkDestroy("placement-failure"),
+	})
+}
+
 func testResourceJob_parameterizedCheck(s *terraform.State) error {
 	resourceState := s.Modules[0].Resources["nomad_job.parameterized"]
 	if resourceState == nil {
@@ -538,6 +805,10 @@ resource "nomad_job" "test" {
 }
 `
 
+var testResourceJob_detectDriftConfig = strings.Replace(testResourceJob_initialConfig,
+	`resource "nomad_job" "test" {`, `resource "nomad_job" "test" {
+	detect_drift = true`, 1)
+
 var testResourceJob_initialConfigNamespace = `
 resource "nomad_namespace" "test-namespace" {
   name = "jobresource-test-namespace"
@@ -950,6 +1221,40 @@ resource "nomad_job" "test" {
 }
 `
 
+var testResourceJob_destroyModeStop = `
+resource "nomad_job" "test" {
+    destroy_mode                    = "stop"
+    wait_for_allocations_on_destroy = true
+    jobspec = <<EOT
+		job "foo" {
+			datacenters = ["dc1"]
+			type = "service"
+			group "foo" {
+				task "foo" {
+					driver = "raw_exec"
+					config {
+						command = "/bin/sleep"
+						args = ["30"]
+					}
+
+					kill_timeout = "2s"
+
+					resources {
+						cpu = 100
+						memory = 10
+					}
+
+					logs {
+						max_files = 3
+						max_file_size = 10
+					}
+				}
+			}
+		}
+	EOT
+}
+`
+
 var testResourceJob_purgeOnDestroy = `
 resource "nomad_job" "test" {
     purge_on_destroy = true
@@ -1695,6 +2000,23 @@ func testResourceJob_deregister(t *testing.T, jobID string) func() {
 	}
 }
 
+func testResourceJob_updateMeta(t *testing.T, jobID string) func() {
+	return func() {
+		client := testProvider.Meta().(ProviderConfig).client
+		job, _, err := client.Jobs().Info(jobID, nil)
+		if err != nil {
+			t.Fatalf("error reading job: %s", err)
+		}
+		if job.Meta == nil {
+			job.Meta = make(map[string]string)
+		}
+		job.Meta["drift"] = "true"
+		if _, _, err := client.Jobs().Register(job, nil); err != nil {
+			t.Fatalf("error updating job: %s", err)
+		}
+	}
+}
+
 func TestResourceJob_vault(t *testing.T) {
 	re, err := regexp.Compile("bad token")
 	if err != nil {
@@ -1783,28 +2105,1511 @@ func TestVolumeSorting(t *testing.T) {
 	require.ElementsMatch(tg1, tg2)
 }
 
-var testResourceJob_validVaultConfig = `
-provider "nomad" {
+func TestJobTaskGroupsRaw(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	interval := 10 * time.Second
+	minHealthyTime := 30 * time.Second
+	tgs := []*api.TaskGroup{
+		{
+			Name:  helper.StringToPtr("web"),
+			Count: intPtr(2),
+			Networks: []*api.NetworkResource{
+				{
+					Mode:          "bridge",
+					ReservedPorts: []api.Port{{Label: "admin", Value: 9000}},
+					DynamicPorts:  []api.Port{{Label: "http", To: 8080}},
+				},
+			},
+			Services: []*api.Service{
+				{
+					Name:      "web",
+					PortLabel: "http",
+					Tags:      []string{"lb"},
+					Checks: []api.ServiceCheck{
+						{Name: "alive", Type: "http", Path: "/health", Interval: interval, Timeout: 2 * time.Second},
+					},
+				},
+			},
+			Update: &api.UpdateStrategy{
+				MaxParallel:    intPtr(1),
+				MinHealthyTime: &minHealthyTime,
+				AutoRevert:     helper.BoolToPtr(true),
+			},
+			RestartPolicy: &api.RestartPolicy{
+				Attempts: intPtr(3),
+				Interval: &minHealthyTime,
+				Delay:    &interval,
+				Mode:     helper.StringToPtr("fail"),
+			},
+			ReschedulePolicy: &api.ReschedulePolicy{
+				Attempts:      intPtr(0),
+				DelayFunction: helper.StringToPtr("exponential"),
+				Unlimited:     helper.BoolToPtr(true),
+			},
+			EphemeralDisk: &api.EphemeralDisk{
+				Sticky: helper.BoolToPtr(true),
+				SizeMB: intPtr(300),
+			},
+			Migrate: &api.MigrateStrategy{
+				MaxParallel:     intPtr(1),
+				HealthCheck:     helper.StringToPtr("checks"),
+				HealthyDeadline: &minHealthyTime,
+			},
+			Tasks: []*api.Task{
+				{
+					Name:   "web",
+					Driver: "docker",
+					Config: map[string]interface{}{
+						"image": "nginx:alpine",
+						"ports": []interface{}{"http"},
+					},
+					Constraints: []*api.Constraint{
+						{LTarget: "${attr.kernel.name}", RTarget: "linux", Operand: "="},
+					},
+					Resources: &api.Resources{
+						CPU:      intPtr(100),
+						MemoryMB: intPtr(64),
+					},
+					Artifacts: []*api.TaskArtifact{
+						{GetterSource: helper.StringToPtr("https://example.com/site.tgz"), RelativeDest: helper.StringToPtr("local/site")},
+					},
+					Templates: []*api.Template{
+						{DestPath: helper.StringToPtr("local/env"), EmbeddedTmpl: helper.StringToPtr("FOO=bar"), Envvars: helper.BoolToPtr(true)},
+					},
+				},
+			},
+		},
+	}
+
+	raw := jobTaskGroupsRaw(tgs)
+	require.Len(t, raw, 1)
+	require.NoError(t, dataSourceJob().TestResourceData().Set("task_groups", raw))
+	tg := raw[0].(map[string]interface{})
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"mode": "bridge",
+			"ports": []interface{}{
+				map[string]interface{}{"label": "admin", "static": true, "value": 9000, "to": 0, "host_network": ""},
+				map[string]interface{}{"label": "http", "static": false, "value": 0, "to": 8080, "host_network": ""},
+			},
+		},
+	}, tg["networks"])
+
+	service := tg["services"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "web", service["name"])
+	require.Equal(t, "http", service["port_label"])
+	require.Equal(t, []string{"lb"}, service["tags"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":       "alive",
+			"type":       "http",
+			"path":       "/health",
+			"protocol":   "",
+			"port_label": "",
+			"interval":   "10s",
+			"timeout":    "2s",
+		},
+	}, service["checks"])
+
+	update := tg["update_strategy"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, 1, update["max_parallel"])
+	require.Equal(t, "30s", update["min_healthy_time"])
+	require.Equal(t, "", update["healthy_deadline"])
+	require.Equal(t, true, update["auto_revert"])
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"attempts": 3, "interval": "30s", "delay": "10s", "mode": "fail"},
+	}, tg["restart_policy"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"attempts":       0,
+			"interval":       "",
+			"delay":          "",
+			"delay_function": "exponential",
+			"max_delay":      "",
+			"unlimited":      true,
+		},
+	}, tg["reschedule_policy"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"sticky": true, "migrate": false, "size_mb": 300},
+	}, tg["ephemeral_disk"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"max_parallel": 1, "health_check": "checks", "min_healthy_time": "", "healthy_deadline": "30s"},
+	}, tg["migrate_strategy"])
+
+	task := tg["task"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{
+		"image": "nginx:alpine",
+		"ports": `["http"]`,
+	}, task["config"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"ltarget": "${attr.kernel.name}", "rtarget": "linux", "operand": "="},
+	}, task["constraints"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"cpu": 100, "memory": 64, "networks": []interface{}{}},
+	}, task["resources"])
+	require.Equal(t, "https://example.com/site.tgz",
+		task["artifacts"].([]interface{})[0].(map[string]interface{})["source"])
+	require.Equal(t, map[string]interface{}{
+		"source":        "",
+		"destination":   "local/env",
+		"data":          "FOO=bar",
+		"change_mode":   "",
+		"change_signal": "",
+		"perms":         "",
+		"env":           true,
+	}, task["templates"].([]interface{})[0])
+}
+
+func TestFormatJobPlan(t *testing.T) {
+	resp := &api.JobPlanResponse{
+		Diff: &api.JobDiff{
+			Type: "Edited",
+			ID:   "foo",
+			TaskGroups: []*api.TaskGroupDiff{
+				{
+					Type: "Edited",
+					Name: "foo",
+					Updates: map[string]uint64{
+						"create/destroy update": 1,
+						"ignore":                2,
+						"in-place update":       0,
+					},
+					Tasks: []*api.TaskDiff{
+						{
+							Type:        "Edited",
+							Name:        "foo",
+							Annotations: []string{"forces create/destroy update"},
+							Objects: []*api.ObjectDiff{
+								{
+									Type: "Edited",
+									Name: "Config",
+									Fields: []*api.FieldDiff{
+										{Type: "Edited", Name: "args[0]", Old: "1", New: "10"},
+										{Type: "None", Name: "command", Old: "/bin/sleep", New: "/bin/sleep"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	expected := `+/- Job: "foo"
++/- Task Group: "foo" (1 create/destroy update, 2 ignore)
+  +/- Task: "foo" (forces create/destroy update)
+    +/- Config {
+      +/- args[0]: "1" => "10"
+    }`
+
+	require.Equal(t, expected, formatJobPlan(resp))
+	require.Equal(t, "", formatJobPlan(nil))
+}
+
+func TestJobPlanWarnings(t *testing.T) {
+	resp := &api.JobPlanResponse{
+		Warnings: "Group \"web\" has warnings: deprecated field\n",
+		Diff: &api.JobDiff{
+			Type: "Edited",
+			ID:   "foo",
+			TaskGroups: []*api.TaskGroupDiff{
+				{
+					Type:    "Edited",
+					Name:    "web",
+					Updates: map[string]uint64{"create/destroy update": 2, "in-place update": 1},
+				},
+				{
+					Type:    "Deleted",
+					Name:    "cache",
+					Updates: map[string]uint64{"destroy": 1},
+				},
+			},
+		},
+		Annotations: &api.PlanAnnotations{
+			PreemptedAllocs: []*api.AllocationListStub{{ID: "a"}},
+		},
+		FailedTGAllocs: map[string]*api.AllocationMetric{
+			"db": {CoalescedFailures: 2},
+		},
+	}
+
+	require.Equal(t, []string{
+		`Group "web" has warnings: deprecated field`,
+		`task group "web" will have 2 create/destroy update`,
+		`task group "cache" will have 1 destroy`,
+		"1 allocation(s) will be preempted",
+		`task group "db" failed to place 3 allocation(s)`,
+	}, jobPlanWarnings(resp))
+	require.Empty(t, jobPlanWarnings(nil))
+	require.Empty(t, jobPlanWarnings(&api.JobPlanResponse{}))
+}
+
+func TestFormatPlacementFailures(t *testing.T) {
+	failed := map[string]*api.AllocationMetric{
+		"cache": {
+			NodesEvaluated:     3,
+			NodesFiltered:      1,
+			NodesExhausted:     2,
+			NodesAvailable:     map[string]int{"dc1": 3, "dc2": 0},
+			ConstraintFiltered: map[string]int{"${attr.kernel.name} = linux": 1},
+			DimensionExhausted: map[string]int{"memory": 2},
+			CoalescedFailures:  1,
+		},
+		"web": {},
+	}
+
+	expected := `task group "cache" (failed to place 2 allocation(s)):
+  * 3 node(s) evaluated, 1 filtered, 2 exhausted
+  * No nodes are available in datacenter "dc2"
+  * Constraint "${attr.kernel.name} = linux": 1 node(s) excluded by filter
+  * Dimension "memory" exhausted on 2 node(s)
+task group "web" (failed to place 1 allocation(s)):
+  * No nodes were eligible for evaluation`
+
+	require.Equal(t, expected, formatPlacementFailures(failed))
 }
 
+var testResourceJob_placementFailureConfig = `
 resource "nomad_job" "test" {
+	fail_on_placement_failure = true
 	jobspec = <<EOT
-		job "test" {
+		job "placement-failure" {
 			datacenters = ["dc1"]
-			type = "batch"
+			type = "service"
 			group "foo" {
 				task "foo" {
 					driver = "raw_exec"
 					config {
-						command = "/usr/bin/true"
+						command = "/bin/sleep"
+						args = ["10"]
 					}
 
 					resources {
 						cpu = 100
-						memory = 10
+						memory = 100000000
 					}
-
-					logs {
+				}
+			}
+		}
+	EOT
+}
+`
+
+func TestParseHCL2Jobspec(t *testing.T) {
+	spec := `
+variable "image" {
+  type = string
+}
+
+variable "datacenters" {
+  type    = list(string)
+  default = ["dc1"]
+}
+
+variable "count" {
+  type    = number
+  default = 1
+}
+
+locals {
+  name   = "${local.prefix}-app"
+  prefix = "my"
+}
+
+job "foo" {
+  datacenters = var.datacenters
+
+  constraint {
+    attribute = "$${attr.kernel.name}"
+    value     = "linux"
+  }
+
+  group "foo" {
+    count = var.count
+
+    dynamic "task" {
+      for_each = ["a", "b"]
+      labels   = [task.value]
+
+      content {
+        driver = "docker"
+
+        config {
+          image = var.image
+          args  = ["$${NOMAD_PORT_http}", upper(task.value)]
+        }
+
+        env {
+          NAME = local.name
+        }
+      }
+    }
+  }
+}
+`
+
+	job, err := parseHCL2Jobspec(spec, map[string]string{
+		"image": "redis:6",
+		"count": "3",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "foo", *job.ID)
+	require.Equal(t, []string{"dc1"}, job.Datacenters)
+	require.Equal(t, "${attr.kernel.name}", job.Constraints[0].LTarget)
+	require.Len(t, job.TaskGroups, 1)
+
+	tg := job.TaskGroups[0]
+	require.Equal(t, 3, *tg.Count)
+	require.Len(t, tg.Tasks, 2)
+	require.Equal(t, "a", tg.Tasks[0].Name)
+	require.Equal(t, "b", tg.Tasks[1].Name)
+	require.Equal(t, "redis:6", tg.Tasks[0].Config["image"])
+	require.Equal(t, []interface{}{"${NOMAD_PORT_http}", "B"}, tg.Tasks[1].Config["args"])
+	require.Equal(t, "my-app", tg.Tasks[0].Env["NAME"])
+
+	_, err = parseHCL2Jobspec(spec, nil)
+	require.EqualError(t, err, `line 2, column 1: Missing variable value; The variable "image" is required but no value was given.
+
+   2 | variable "image" {
+     | ^`)
+
+	_, err = parseHCL2Jobspec(spec, map[string]string{"image": "redis:6", "foo": "bar"})
+	require.EqualError(t, err, `Undeclared variable; A value was given for the undeclared variable "foo".`)
+}
+
+func TestParseHCL2Jobspec_values(t *testing.T) {
+	spec := `
+locals {
+  ports = [
+    { label = "http", to = 8080 },
+    { label = "admin", to = 9090 },
+  ]
+}
+
+job "foo" {
+  datacenters = ["dc1"]
+
+  group "foo" {
+    dynamic "network" {
+      for_each = [local.ports]
+      iterator = ports
+
+      content {
+        dynamic "port" {
+          for_each = ports.value
+          labels   = [port.value.label]
+
+          content {
+            to = port.value.to
+          }
+        }
+      }
+    }
+
+    task "foo" {
+      driver = "docker"
+
+      config {
+        image = "nginx"
+        mounts = [
+          { type = "bind", target = "/etc/nginx", readonly = true },
+        ]
+        labels = { team = "web", "com.example.zone" = "eu" }
+      }
+
+      meta = {
+        ports = length(local.ports)
+        unset = null
+      }
+    }
+  }
+}
+`
+	job, err := parseHCL2Jobspec(spec, nil)
+	require.NoError(t, err)
+
+	tg := job.TaskGroups[0]
+	require.Len(t, tg.Networks, 1)
+	require.Equal(t, []api.Port{{Label: "http", To: 8080}, {Label: "admin", To: 9090}}, tg.Networks[0].DynamicPorts)
+
+	config := tg.Tasks[0].Config
+	require.Equal(t, []map[string]interface{}{
+		{"type": "bind", "target": "/etc/nginx", "readonly": true},
+	}, config["mounts"])
+	require.Equal(t, []map[string]interface{}{
+		{"team": "web", "com.example.zone": "eu"},
+	}, config["labels"])
+	require.Equal(t, map[string]string{"ports": "2"}, tg.Tasks[0].Meta)
+}
+
+func TestParseHCL2Jobspec_errors(t *testing.T) {
+	testCases := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{
+			name: "unknown function",
+			spec: `job "foo" {
+  datacenters = [uuid()]
+}`,
+			err: `line 2, column 18 (job "foo"): Call to unknown function; There is no function named "uuid".
+
+   2 |   datacenters = [uuid()]
+     |                  ^`,
+		},
+		{
+			name: "dynamic block without content",
+			spec: `job "foo" {
+  dynamic "group" {
+    for_each = ["a"]
+  }
+}`,
+			err: `line 2, column 19 (job "foo"): Missing dynamic content block; A dynamic block must have a nested block of type "content" to describe the body of each generated block.
+
+   2 |   dynamic "group" {
+     |                   ^`,
+		},
+		{
+			name: "dynamic block on unknown collection",
+			spec: `job "foo" {
+  dynamic "group" {
+    for_each = "a"
+    content {}
+  }
+}`,
+			err: `line 3, column 16 (job "foo"): Invalid dynamic for_each value; Cannot use a string value in for_each. An iterable collection is required.
+
+   3 |     for_each = "a"
+     |                ^`,
+		},
+		{
+			name: "unresolved reference",
+			spec: `job "foo" {
+  group "bar" {
+    task "baz" {
+      driver = "docker"
+      config {
+        image = "${NOMAD_META_image}"
+      }
+    }
+  }
+}`,
+			err: `line 6, column 20 (job "foo" > group "bar" > task "baz"): Unknown variable; There is no variable named "NOMAD_META_image".
+
+   6 |         image = "${NOMAD_META_image}"
+     |                    ^`,
+		},
+		{
+			name: "unresolved local",
+			spec: `locals {
+  a = local.b
+}
+
+job "foo" {}`,
+			err: `line 2, column 12: Unsupported attribute; This object does not have an attribute named "b".
+
+   2 |   a = local.b
+     |            ^`,
+		},
+		{
+			name: "invalid job",
+			spec: `job "foo" {
+  group "bar" {
+    count = "x"
+  }
+}`,
+			err: `line 1, column 1 (job "foo"): cannot parse 'Count' as int: strconv.ParseInt: parsing "x": invalid syntax
+
+   1 | job "foo" {
+     | ^`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseHCL2Jobspec(tc.spec, nil)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+var testResourceJob_hcl2Config = `
+resource "nomad_job" "hcl2" {
+	hcl2 {
+		enabled = true
+		vars = {
+			"count" = "2"
+		}
+	}
+
+	jobspec = <<EOT
+		variable "count" {
+			type = number
+		}
+
+		locals {
+			tasks = ["a", "b"]
+		}
+
+		job "foo-hcl2" {
+			datacenters = ["dc1"]
+			type = "service"
+			group "foo" {
+				count = var.count
+
+				dynamic "task" {
+					for_each = local.tasks
+					labels   = [task.value]
+
+					content {
+						driver = "raw_exec"
+						config {
+							command = "/bin/sleep"
+							args    = ["10"]
+						}
+
+						meta = {
+							tasks = length(local.tasks)
+						}
+
+						resources {
+							cpu    = 20
+							memory = 10
+						}
+					}
+				}
+			}
+		}
+	EOT
+}
+`
+
+func TestJobTokens(t *testing.T) {
+	providerConsulToken := "provider-consul"
+	providerConfig := ProviderConfig{
+		vaultToken:  staticTokenSource("provider-vault"),
+		consulToken: &providerConsulToken,
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{})
+	vaultToken, consulToken, err := jobTokens(d, providerConfig)
+	require.NoError(t, err)
+	require.Equal(t, "provider-vault", *vaultToken)
+	require.Equal(t, "provider-consul", *consulToken)
+
+	d = schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{
+		"vault_token":  "job-vault",
+		"consul_token": "job-consul",
+	})
+	vaultToken, consulToken, err = jobTokens(d, providerConfig)
+	require.NoError(t, err)
+	require.Equal(t, "job-vault", *vaultToken)
+	require.Equal(t, "job-consul", *consulToken)
+}
+
+func TestApplyJobOverrides(t *testing.T) {
+	newJob := func() *api.Job {
+		return &api.Job{
+			ID:          helper.StringToPtr("foo"),
+			Datacenters: []string{"dc1"},
+			Meta:        map[string]string{"owner": "ops"},
+			TaskGroups: []*api.TaskGroup{
+				{
+					Name: helper.StringToPtr("web"),
+					Tasks: []*api.Task{
+						{
+							Name:   "nginx",
+							Driver: "docker",
+							Config: map[string]interface{}{"image": "nginx:1.18"},
+							Env:    map[string]string{"LEVEL": "info"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	job := newJob()
+	d := schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{})
+	require.NoError(t, applyJobOverrides(job, d))
+	require.Equal(t, newJob(), job)
+
+	d = schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{
+		"overrides": []interface{}{
+			map[string]interface{}{
+				"datacenters": []interface{}{"dc2", "dc3"},
+				"meta":        map[string]interface{}{"env": "staging"},
+				"group": []interface{}{
+					map[string]interface{}{
+						"name":  "web",
+						"count": 3,
+						"meta":  map[string]interface{}{"tier": "front"},
+						"task": []interface{}{
+							map[string]interface{}{
+								"name":  "nginx",
+								"image": "nginx:1.19",
+								"env":   map[string]interface{}{"LEVEL": "debug"},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, applyJobOverrides(job, d))
+	require.Equal(t, []string{"dc2", "dc3"}, job.Datacenters)
+	require.Equal(t, map[string]string{"owner": "ops", "env": "staging"}, job.Meta)
+	require.Equal(t, 3, *job.TaskGroups[0].Count)
+	require.Equal(t, map[string]string{"tier": "front"}, job.TaskGroups[0].Meta)
+	require.Equal(t, "nginx:1.19", job.TaskGroups[0].Tasks[0].Config["image"])
+	require.Equal(t, map[string]string{"LEVEL": "debug"}, job.TaskGroups[0].Tasks[0].Env)
+	require.Nil(t, job.TaskGroups[0].Tasks[0].Meta)
+
+	// Unset counts keep the count of the jobspec
+	job = newJob()
+	d = schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{
+		"overrides": []interface{}{
+			map[string]interface{}{
+				"group": []interface{}{
+					map[string]interface{}{"name": "web"},
+				},
+			},
+		},
+	})
+	require.NoError(t, applyJobOverrides(job, d))
+	require.Nil(t, job.TaskGroups[0].Count)
+
+	d = schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{
+		"overrides": []interface{}{
+			map[string]interface{}{
+				"group": []interface{}{
+					map[string]interface{}{"name": "api"},
+				},
+			},
+		},
+	})
+	require.EqualError(t, applyJobOverrides(newJob(), d),
+		`error applying overrides: task group "api" not found in job`)
+
+	d = schema.TestResourceDataRaw(t, resourceJob().Schema, map[string]interface{}{
+		"overrides": []interface{}{
+			map[string]interface{}{
+				"group": []interface{}{
+					map[string]interface{}{
+						"name": "web",
+						"task": []interface{}{
+							map[string]interface{}{"name": "redis"},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.EqualError(t, applyJobOverrides(newJob(), d),
+		`error applying overrides: task "redis" not found in task group "web"`)
+}
+
+func TestJobspecDiffSuppress(t *testing.T) {
+	hclJobspec := `
+job "foo" {
+  datacenters = ["dc1"]
+  group "bar" {
+    task "baz" {
+      driver = "raw_exec"
+      config {
+        command = "/bin/sleep"
+      }
+    }
+  }
+}`
+	hclJobspecReordered := `
+job "foo" {
+  group "bar" {
+    task "baz" {
+      config {
+        command = "/bin/sleep"
+      }
+      driver = "raw_exec"
+    }
+  }
+  datacenters = ["dc1"]
+}`
+	jsonJobspec := `{
+  "Job": {
+    "ID": "foo",
+    "Name": "foo",
+    "Datacenters": ["dc1"],
+    "TaskGroups": [{
+      "Name": "bar",
+      "Tasks": [{
+        "Name": "baz",
+        "Driver": "raw_exec",
+        "Config": {"command": "/bin/sleep"}
+      }]
+    }]
+  }
+}`
+	jsonJobspecNoRoot := `{
+  "Datacenters": ["dc1"],
+  "TaskGroups": [{
+    "Tasks": [{
+      "Config": {"command": "/bin/sleep"},
+      "Driver": "raw_exec",
+      "Name": "baz"
+    }],
+    "Name": "bar"
+  }],
+  "Name": "foo",
+  "ID": "foo"
+}`
+	liveJobspec := `{
+  "Job": {
+    "ID": "foo",
+    "Name": "foo",
+    "Status": "running",
+    "Version": 3,
+    "JobModifyIndex": 42,
+    "Datacenters": ["dc1"],
+    "TaskGroups": [{
+      "Name": "bar",
+      "Tasks": [{
+        "Name": "baz",
+        "Driver": "raw_exec",
+        "Config": {"command": "/bin/sleep"}
+      }]
+    }]
+  }
+}`
+	hcl2Jobspec := `
+variable "command" {
+  type = string
+}
+
+job "foo" {
+  datacenters = ["dc1"]
+  group "bar" {
+    task "baz" {
+      driver = "raw_exec"
+      config {
+        command = var.command
+      }
+    }
+  }
+}`
+	hclJobspecChanged := strings.Replace(hclJobspec, "/bin/sleep", "/bin/echo", 1)
+	hclJobspecScaled := strings.Replace(hclJobspec, `group "bar" {`, `group "bar" {
+    count = 2
+    scaling {
+      max = 10
+    }`, 1)
+	hclJobspecScaledCount := strings.Replace(hclJobspecScaled, "count = 2", "count = 5", 1)
+	hclJobspecScalingDisabled := strings.Replace(hclJobspecScaled, "max = 10", "max = 10\n      enabled = false", 1)
+	hclJobspecScalingDisabledCount := strings.Replace(hclJobspecScalingDisabled, "count = 2", "count = 5", 1)
+	preserveCounts := map[string]interface{}{"preserve_counts": true}
+	hcl2Config := map[string]interface{}{
+		"hcl2": []interface{}{
+			map[string]interface{}{
+				"enabled": true,
+				"vars":    map[string]interface{}{"command": "/bin/sleep"},
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		config   map[string]interface{}
+		old, new string
+		suppress bool
+	}{
+		{"hcl reordered", nil, hclJobspec, hclJobspecReordered, true},
+		{"hcl changed", nil, hclJobspec, hclJobspecChanged, false},
+		{"hcl to json", map[string]interface{}{"json": true}, hclJobspec, jsonJobspec, true},
+		{"hcl to hcl2", hcl2Config, hclJobspec, hcl2Jobspec, true},
+		{"json without root", map[string]interface{}{"json": true}, jsonJobspec, jsonJobspecNoRoot, true},
+		{"live json in state", nil, liveJobspec, hclJobspec, true},
+		{"live json in state changed", nil, liveJobspec, hclJobspecChanged, false},
+		{"invalid", nil, hclJobspec, "job {", false},
+		{"scaled count", nil, hclJobspecScaled, hclJobspecScaledCount, false},
+		{"scaled count preserved", preserveCounts, hclJobspecScaled, hclJobspecScaledCount, true},
+		{"scaled count preserved changed", preserveCounts, hclJobspecScaled, strings.Replace(hclJobspecScaledCount, "/bin/sleep", "/bin/echo", 1), false},
+		{"scaling disabled count preserved", preserveCounts, hclJobspecScalingDisabled, hclJobspecScalingDisabledCount, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceJob().Schema, c.config)
+			require.Equal(t, c.suppress, jobspecDiffSuppress("jobspec", c.old, c.new, d))
+		})
+	}
+}
+
+func TestJobspecDiagnostics(t *testing.T) {
+	type diag struct {
+		line, column int
+		path         string
+		summary      string
+	}
+	diagsOf := func(err error) []diag {
+		merr, ok := err.(*multierror.Error)
+		require.True(t, ok, "unexpected error type %T: %s", err, err)
+		var diags []diag
+		for _, e := range merr.Errors {
+			d := e.(*jobspecDiagnostic)
+			diags = append(diags, diag{d.Line, d.Column, strings.Join(d.Path, " > "), d.Summary})
+		}
+		return diags
+	}
+
+	_, err := parseHCLJobspec(`
+job "foo" {
+  group "bar" {
+    task "baz" {
+      driver = "raw_exec"
+      foo    = 1
+    }
+  }
+}`)
+	require.Equal(t, []diag{
+		{2, 1, `job "foo"`, "group: 'bar', task: 'baz', invalid key: foo"},
+	}, diagsOf(err))
+	require.Contains(t, err.Error(), "   2 | job \"foo\" {\n     | ^")
+
+	_, err = parseHCLJobspec(`
+job "foo" {
+  group "bar" {
+    count = = 1
+  }
+}`)
+	require.Equal(t, []diag{
+		{4, 13, "", "Unknown token: 4:13 ASSIGN ="},
+	}, diagsOf(err))
+
+	_, err = parseHCL2Jobspec(`
+job "foo" {
+  group "bar" {
+    task "baz" {
+      driver = 
+    }
+  }
+}`, nil)
+	require.Equal(t, []diag{
+		{5, 16, `job "foo" > group "bar" > task "baz"`, "Invalid expression; Expected the start of an expression, but found an invalid expression token."},
+	}, diagsOf(err))
+
+	_, err = parseJSONJobspec(`{
+  "Job": {
+    "ID": "foo",
+    "TaskGroups": [{"Name": "bar", "Count": "x"}]
+  }
+}`)
+	diags := diagsOf(err)
+	require.Len(t, diags, 1)
+	require.Equal(t, 4, diags[0].line)
+	require.Equal(t, "cannot use a string value as int", diags[0].summary)
+	require.Contains(t, diags[0].path, "Count")
+
+	_, err = parseJSONJobspec("{\"Job\": {\n  \"ID\": \"foo\",,\n}}")
+	require.Equal(t, []diag{
+		{2, 15, "", "invalid character ',' looking for beginning of object key string"},
+	}, diagsOf(err))
+}
+
+func TestJobspecDiagnostics_errorStrings(t *testing.T) {
+	spec := `
+job "foo" {
+  group "bar" {
+    task "baz" {
+      driver = "raw_exec"
+      foo    = 1
+      bar    = 1
+    }
+  }
+}`
+	_, err := parseJobspec(spec, JobParserConfig{}, nil, nil)
+	require.EqualError(t, err, `error parsing jobspec: line 2, column 1 (job "foo"): group: 'bar', task: 'baz', invalid key: foo; group: 'bar', task: 'baz', invalid key: bar
+
+   2 | job "foo" {
+     | ^`)
+
+	_, err = parseJobspec("{\"Job\": {\"ID\": 1}}", JobParserConfig{JSON: true}, nil, nil)
+	require.EqualError(t, err, `error parsing jobspec: line 1, column 16 (Job > ID): cannot use a number value as string
+
+   1 | {"Job": {"ID": 1}}
+     |                ^`)
+}
+
+func TestJobspecFromJob(t *testing.T) {
+	version, modifyIndex := uint64(3), uint64(42)
+	job := &api.Job{
+		ID:             helper.StringToPtr("foo"),
+		Name:           helper.StringToPtr("foo"),
+		Namespace:      helper.StringToPtr("default"),
+		Datacenters:    []string{"dc1"},
+		Status:         helper.StringToPtr("running"),
+		Version:        &version,
+		JobModifyIndex: &modifyIndex,
+	}
+
+	jobspecJSON, err := jobspecFromJob(job)
+	require.NoError(t, err)
+
+	parsed, err := parseJSONJobspec(jobspecJSON)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, parsed.ID)
+	require.Equal(t, job.Datacenters, parsed.Datacenters)
+	require.Nil(t, parsed.Status)
+	require.Nil(t, parsed.Version)
+	require.Nil(t, parsed.JobModifyIndex)
+
+	// The original job must not be modified.
+	require.Equal(t, "running", *job.Status)
+}
+
+func TestApplyTaskGroupCounts(t *testing.T) {
+	newJob := func(counts ...int) *api.Job {
+		job := &api.Job{}
+		for i, c := range counts {
+			count := c
+			job.TaskGroups = append(job.TaskGroups, &api.TaskGroup{
+				Name:  helper.StringToPtr(fmt.Sprintf("group-%d", i)),
+				Count: &count,
+			})
+		}
+		return job
+	}
+
+	job := newJob(1, 1, 1)
+	job.TaskGroups[0].Scaling = &api.ScalingPolicy{}
+	job.TaskGroups[1].Scaling = &api.ScalingPolicy{Enabled: helper.BoolToPtr(false)}
+
+	applyTaskGroupCounts(job, newJob(5, 5, 5))
+
+	// Only the group with an enabled scaling policy keeps its current count.
+	require.Equal(t, 5, *job.TaskGroups[0].Count)
+	require.Equal(t, 1, *job.TaskGroups[1].Count)
+	require.Equal(t, 1, *job.TaskGroups[2].Count)
+}
+
+// testNomadAPI starts an HTTP server answering the requests of the Nomad API
+// with the JSON encoding of the value returned by the handler of their path,
+// and returns a client for it.
+func testNomadAPI(t *testing.T, handlers map[string]func(r *http.Request) interface{}) *api.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.URL.Path]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Nomad-Index", "1")
+		w.Header().Set("X-Nomad-KnownLeader", "true")
+		w.Header().Set("X-Nomad-LastContact", "0")
+		require.NoError(t, json.NewEncoder(w).Encode(handler(r)))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	return client
+}
+
+func TestBatchJobStateRefreshFunc(t *testing.T) {
+	evalStatus := "pending"
+	summary := map[string]api.TaskGroupSummary{}
+	var allocs []*api.AllocationListStub
+
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/evaluation/eval-1": func(*http.Request) interface{} {
+			return &api.Evaluation{ID: "eval-1", Status: evalStatus}
+		},
+		"/v1/job/batch/summary": func(*http.Request) interface{} {
+			return &api.JobSummary{JobID: "batch", Summary: summary}
+		},
+		"/v1/job/batch/allocations": func(*http.Request) interface{} {
+			return allocs
+		},
+	})
+	refresh := batchJobStateRefreshFunc(client, "eval-1", "batch", "default", 0)
+
+	// The job has no allocation until the evaluation is processed
+	_, state, err := refresh()
+	require.NoError(t, err)
+	require.Equal(t, MonitoringAllocations, state)
+
+	evalStatus = "complete"
+	summary["foo"] = api.TaskGroupSummary{Starting: 1}
+	_, state, err = refresh()
+	require.NoError(t, err)
+	require.Equal(t, MonitoringAllocations, state)
+
+	summary["foo"] = api.TaskGroupSummary{Running: 1}
+	allocs = []*api.AllocationListStub{{ID: "alloc-1", TaskGroup: "foo", ClientStatus: "running"}}
+	_, state, err = refresh()
+	require.NoError(t, err)
+	require.Equal(t, MonitoringAllocations, state)
+
+	summary["foo"] = api.TaskGroupSummary{Complete: 1}
+	allocs[0].ClientStatus = "complete"
+	_, state, err = refresh()
+	require.NoError(t, err)
+	require.Equal(t, AllocationsComplete, state)
+
+	// A failed evaluation is reported
+	evalStatus = "failed"
+	_, _, err = batchJobStateRefreshFunc(client, "eval-1", "batch", "default", 0)()
+	require.Error(t, err)
+}
+
+func TestDeploymentStateRefreshFunc_promotion(t *testing.T) {
+	deployment := &api.Deployment{
+		ID:                "deployment-1",
+		Namespace:         "default",
+		Status:            deploymentStatusRunning,
+		StatusDescription: deploymentStatusDescriptionNeedsPromotion,
+		TaskGroups: map[string]*api.DeploymentState{
+			"web": {DesiredCanaries: 2, DesiredTotal: 4},
+			"db":  {DesiredTotal: 1},
+		},
+	}
+	promotions := 0
+
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/deployment/deployment-1": func(*http.Request) interface{} {
+			return deployment
+		},
+		"/v1/deployment/promote/deployment-1": func(*http.Request) interface{} {
+			promotions++
+			return &api.DeploymentUpdateResponse{}
+		},
+	})
+
+	// The canaries are not placed or not healthy yet
+	for _, healthy := range []int{0, 1} {
+		deployment.TaskGroups["web"].HealthyAllocs = healthy
+
+		_, state, err := deploymentStateRefreshFunc(client, "deployment-1", true)()
+		require.NoError(t, err)
+		require.Equal(t, MonitoringDeployment, state)
+
+		_, state, err = deploymentStateRefreshFunc(client, "deployment-1", false)()
+		require.NoError(t, err)
+		require.Equal(t, MonitoringDeployment, state)
+	}
+	require.Equal(t, 0, promotions)
+
+	deployment.TaskGroups["web"].HealthyAllocs = 2
+
+	_, state, err := deploymentStateRefreshFunc(client, "deployment-1", false)()
+	require.NoError(t, err)
+	require.Equal(t, DeploymentNeedsPromotion, state)
+	require.Equal(t, 0, promotions)
+
+	_, state, err = deploymentStateRefreshFunc(client, "deployment-1", true)()
+	require.NoError(t, err)
+	require.Equal(t, MonitoringDeployment, state)
+	require.Equal(t, 1, promotions)
+}
+
+func TestResourceJobDetectDrift(t *testing.T) {
+	planDiff := &api.JobDiff{Type: diffTypeNone, ID: "foo"}
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo/plan": func(*http.Request) interface{} {
+			return &api.JobPlanResponse{Diff: planDiff}
+		},
+	})
+
+	jobspec := `job "foo" {
+  group "foo" {
+    task "foo" {
+      driver = "raw_exec"
+    }
+  }
+}`
+	d := resourceJob().TestResourceData()
+	d.SetId("foo")
+	d.Set("jobspec", jobspec)
+	d.Set("vault_token", "vault")
+	providerConfig := ProviderConfig{client: client}
+	live := &api.Job{ID: helper.StringToPtr("foo")}
+
+	drift, err := resourceJobDetectDrift(d, providerConfig, live)
+	require.NoError(t, err)
+	require.Empty(t, drift)
+
+	planDiff = &api.JobDiff{
+		Type: "Edited",
+		ID:   "foo",
+		Fields: []*api.FieldDiff{
+			{Type: "Deleted", Name: "Meta[drift]", Old: "true"},
+		},
+	}
+	drift, err = resourceJobDetectDrift(d, providerConfig, live)
+	require.NoError(t, err)
+	require.Contains(t, drift, `+/- Job: "foo"`)
+	require.Contains(t, drift, "Meta[drift]")
+
+	// The jobspec in the state is kept as is
+	require.Equal(t, jobspec, d.Get("jobspec"))
+}
+
+func TestResourceJobDetectDrift_forbidden(t *testing.T) {
+	status := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Permission denied", status)
+	}))
+	t.Cleanup(server.Close)
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	d := resourceJob().TestResourceData()
+	d.SetId("foo")
+	d.Set("jobspec", `job "foo" {}`)
+	d.Set("vault_token", "vault")
+	providerConfig := ProviderConfig{client: client}
+	live := &api.Job{ID: helper.StringToPtr("foo")}
+
+	// A token without submit-job cannot plan, so drift detection is skipped
+	drift, err := resourceJobDetectDrift(d, providerConfig, live)
+	require.NoError(t, err)
+	require.Empty(t, drift)
+
+	// while the other errors are reported
+	status = http.StatusInternalServerError
+	_, err = resourceJobDetectDrift(d, providerConfig, live)
+	require.Error(t, err)
+}
+
+func TestResourceJobImportState_childJob(t *testing.T) {
+	var requests []string
+	job := func(namespace, id string) func(*http.Request) interface{} {
+		return func(r *http.Request) interface{} {
+			requests = append(requests, r.URL.RequestURI())
+			j := api.NewBatchJob(id, id, "global", 50)
+			j.Namespace = &namespace
+			return j
+		}
+	}
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/batch/periodic-1600000000": job("default", "batch/periodic-1600000000"),
+		"/v1/job/foo":                       job("prod", "foo"),
+	})
+
+	testCases := []struct {
+		importID  string
+		id        string
+		namespace string
+		requests  []string
+	}{
+		{
+			importID:  "batch/periodic-1600000000",
+			id:        "batch/periodic-1600000000",
+			namespace: "default",
+			requests:  []string{"/v1/job/batch%2Fperiodic-1600000000?namespace=default"},
+		},
+		{
+			importID:  "default/batch/periodic-1600000000",
+			id:        "batch/periodic-1600000000",
+			namespace: "default",
+			requests:  []string{"/v1/job/batch%2Fperiodic-1600000000?namespace=default"},
+		},
+		{
+			importID:  "prod/foo",
+			id:        "foo",
+			namespace: "prod",
+			requests:  []string{"/v1/job/foo?namespace=prod"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.importID, func(t *testing.T) {
+			requests = nil
+			d := resourceJob().TestResourceData()
+			d.SetId(tc.importID)
+			_, err := resourceJobImportState(d, ProviderConfig{client: client})
+			require.NoError(t, err)
+			require.Equal(t, tc.id, d.Id())
+			require.Equal(t, tc.namespace, d.Get("namespace"))
+			require.Equal(t, tc.requests, requests)
+		})
+	}
+}
+
+func TestResourceJob_planAfterImport(t *testing.T) {
+	jobspec := `
+job "foo" {
+  datacenters = ["dc1"]
+  group "bar" {
+    count = 2
+    task "baz" {
+      driver = "raw_exec"
+      config {
+        command = "/bin/sleep"
+        args    = ["10"]
+      }
+      vault {
+        policies = ["app"]
+      }
+    }
+  }
+}`
+
+	// The job registered in Nomad has its defaults set, the constraints
+	// implied by its features and empty values for the fields that are not
+	// set in the jobspec.
+	live, err := parseJobspec(jobspec, JobParserConfig{}, nil, nil)
+	require.NoError(t, err)
+	live.Canonicalize()
+	version, modifyIndex := uint64(3), uint64(42)
+	live.Version = &version
+	live.JobModifyIndex = &modifyIndex
+	live.Status = helper.StringToPtr("running")
+	live.Affinities = []*api.Affinity{}
+	live.Meta = map[string]string{}
+	live.TaskGroups[0].Constraints = []*api.Constraint{
+		api.NewConstraint("${attr.vault.version}", ">= 0.6.1", ""),
+	}
+	live.TaskGroups[0].Tasks[0].Env = map[string]string{}
+
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo": func(*http.Request) interface{} {
+			return live
+		},
+		"/v1/job/foo/allocations": func(*http.Request) interface{} {
+			return []*api.AllocationListStub{}
+		},
+		"/v1/job/foo/plan": func(*http.Request) interface{} {
+			return &api.JobPlanResponse{JobModifyIndex: modifyIndex}
+		},
+	})
+	meta := ProviderConfig{client: client, vaultToken: staticTokenSource("vault")}
+
+	resource := resourceJob()
+	d := resource.TestResourceData()
+	d.SetId("default/foo")
+	imported, err := resource.Importer.State(d, meta)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	require.NoError(t, resource.Read(imported[0], meta))
+
+	// Planning the original jobspec shows no change
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"jobspec": jobspec,
+	})
+	diff, err := resource.Diff(imported[0].State(), config, meta)
+	require.NoError(t, err)
+	require.True(t, diff.Empty(), "unexpected diff: %#v", diff)
+
+	// Changing the jobspec does
+	config = terraform.NewResourceConfigRaw(map[string]interface{}{
+		"jobspec": strings.Replace(jobspec, "count = 2", "count = 3", 1),
+	})
+	diff, err = resource.Diff(imported[0].State(), config, meta)
+	require.NoError(t, err)
+	require.False(t, diff.Empty())
+	require.Contains(t, diff.Attributes, "jobspec")
+}
+
+func TestFormatAllocationFailures(t *testing.T) {
+	allocs := []*api.AllocationListStub{
+		{
+			ID:                "5a1b2c3d-0000-0000-0000-000000000000",
+			TaskGroup:         "foo",
+			NodeName:          "node-1",
+			ClientStatus:      "failed",
+			ClientDescription: "Failed tasks",
+			TaskStates: map[string]*api.TaskState{
+				"foo": {
+					State:  "dead",
+					Failed: true,
+					Events: []*api.TaskEvent{
+						{Type: "Received", Time: 0, DisplayMessage: "Task received by client"},
+						{Type: "Started", Time: 1e9, DisplayMessage: "Task started by client"},
+						{Type: "Terminated", Time: 2e9, DisplayMessage: "Exit Code: 1",
+							Details: map[string]string{"exit_code": "1"}},
+					},
+				},
+			},
+		},
+	}
+
+	expected := `allocation "5a1b2c3d" (task group "foo", node "node-1") is failed: Failed tasks
+  task "foo" is dead (failed), exit code 1
+    * 1970-01-01T00:00:01Z Started: Task started by client
+    * 1970-01-01T00:00:02Z Terminated: Exit Code: 1`
+
+	require.Equal(t, expected, formatAllocationFailures(allocs, 2))
+}
+
+func TestFlattenAllocationFailures(t *testing.T) {
+	healthy := false
+	allocs := []*api.AllocationListStub{
+		{
+			ID:               "5a1b2c3d-0000-0000-0000-000000000000",
+			TaskGroup:        "foo",
+			NodeName:         "node-1",
+			ClientStatus:     "running",
+			DeploymentStatus: &api.AllocDeploymentStatus{Healthy: &healthy},
+			TaskStates: map[string]*api.TaskState{
+				"foo": {
+					State: "running",
+					Events: []*api.TaskEvent{
+						{Type: "Started", Time: 1e9, DisplayMessage: "Task started by client"},
+						{Type: "Alloc Unhealthy", Time: 2e9, DisplayMessage: "Task not running for min_healthy_time of 10s by deadline"},
+					},
+				},
+			},
+		},
+	}
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"allocation_id":      "5a1b2c3d-0000-0000-0000-000000000000",
+			"task_group":         "foo",
+			"node_name":          "node-1",
+			"client_status":      "running",
+			"client_description": "",
+			"unhealthy":          true,
+			"task": []interface{}{
+				map[string]interface{}{
+					"name":   "foo",
+					"state":  "running",
+					"failed": false,
+					"events": []interface{}{
+						map[string]interface{}{
+							"time":    "1970-01-01T00:00:02Z",
+							"type":    "Alloc Unhealthy",
+							"message": "Task not running for min_healthy_time of 10s by deadline",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, expected, flattenAllocationFailures(allocs, 1))
+	require.Equal(t, `allocation "5a1b2c3d" (task group "foo", node "node-1") is running and unhealthy
+  task "foo" is running
+    * 1970-01-01T00:00:02Z Alloc Unhealthy: Task not running for min_healthy_time of 10s by deadline`,
+		formatAllocationFailures(allocs, 1))
+}
+
+func TestMultiregionDeploymentState(t *testing.T) {
+	deployment := func(status, description string) *api.Deployment {
+		return &api.Deployment{ID: "d-" + status, Status: status, StatusDescription: description}
+	}
+	canaries := func(healthy int) *api.Deployment {
+		d := deployment("running", deploymentStatusDescriptionNeedsPromotion)
+		d.TaskGroups = map[string]*api.DeploymentState{
+			"web": {DesiredCanaries: 2, HealthyAllocs: healthy},
+		}
+		return d
+	}
+	regions := []string{"east", "west"}
+
+	cases := []struct {
+		name        string
+		deployments map[string]*api.Deployment
+		onFailure   string
+		state       string
+		err         string
+	}{
+		{
+			name: "all successful",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("successful", ""),
+				"west": deployment("successful", ""),
+			},
+			state: DeploymentSuccessful,
+		},
+		{
+			name: "waiting for max_parallel",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("blocked", ""),
+			},
+			state: MonitoringDeployment,
+		},
+		{
+			name: "needs promotion",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("blocked", ""),
+				"west": canaries(2),
+			},
+			state: DeploymentNeedsPromotion,
+		},
+		{
+			name: "canaries not healthy",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("blocked", ""),
+				"west": canaries(1),
+			},
+			state: MonitoringDeployment,
+		},
+		{
+			name: "failed while another region is running",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("failed", "Failed due to unhealthy allocations"),
+				"west": deployment("running", ""),
+			},
+			state: MonitoringDeployment,
+		},
+		{
+			name: "failed with blocked regions",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("failed", "Failed due to unhealthy allocations"),
+				"west": deployment("blocked", ""),
+			},
+			err: `multiregion deployment failed:
+region "east": deployment 'd-failed' terminated with status 'failed': 'Failed due to unhealthy allocations'`,
+		},
+		{
+			name: "failed locally with pending regions",
+			deployments: map[string]*api.Deployment{
+				"east": deployment("failed", "Failed due to unhealthy allocations"),
+			},
+			onFailure: multiregionOnFailureFailLocal,
+			state:     MonitoringDeployment,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := multiregionDeploymentState(regions, tc.deployments, tc.onFailure)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.state, state)
+		})
+	}
+}
+
+var testResourceJob_validVaultConfig = `
+provider "nomad" {
+}
+
+resource "nomad_job" "test" {
+	jobspec = <<EOT
+		job "test" {
+			datacenters = ["dc1"]
+			type = "batch"
+			group "foo" {
+				task "foo" {
+					driver = "raw_exec"
+					config {
+						command = "/usr/bin/true"
+					}
+
+					resources {
+						cpu = 100
+						memory = 10
+					}
+
+					logs {
 						max_files = 3
 						max_file_size = 10
 					}
@@ -2261,6 +4066,32 @@ resource "nomad_job" "test" {
 }
 `
 
+var testResourceJob_preserveCountsConfig = `
+resource "nomad_job" "test" {
+	preserve_counts = true
+	jobspec = <<EOT
+	job "foo-preserve-counts" {
+		datacenters = ["dc1"]
+		group "foo" {
+			count = 1
+			scaling {
+				min = 1
+				max = 5
+				enabled = true
+			}
+			task "foo" {
+				driver = "raw_exec"
+				config {
+					command = "/bin/sleep"
+					args = ["10"]
+				}
+			}
+		}
+	}
+	EOT
+}
+`
+
 var testResourceJob_serviceDeploymentInfo = `
 resource "nomad_job" "service" {
   detach = false
@@ -2286,6 +4117,67 @@ job "foo-service-with-deployment" {
 EOT
 }`
 
+func testResourceJob_canaryConfig(promote, version string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "canary" {
+  detach           = false
+  promote_canaries = "%s"
+  jobspec = <<EOT
+job "foo-canary" {
+  type        = "service"
+  datacenters = ["dc1"]
+  group "service" {
+    update {
+      canary           = 1
+      min_healthy_time = "1s"
+      healthy_deadline = "30s"
+    }
+    task "sleep" {
+      driver = "raw_exec"
+      env {
+        version = "%s"
+      }
+      config {
+        command = "sleep"
+        args = ["3600"]
+      }
+    }
+  }
+}
+EOT
+}`, promote, version)
+}
+
+func testResourceJob_batchWaitForCompletionConfig(jobID, command string) string {
+	return fmt.Sprintf(`
+resource "nomad_job" "batch" {
+  detach              = false
+  wait_for_completion = true
+  jobspec = <<EOT
+job "%s" {
+  type        = "batch"
+  datacenters = ["dc1"]
+  group "foo" {
+    restart {
+      attempts = 0
+      mode     = "fail"
+    }
+    reschedule {
+      attempts  = 0
+      unlimited = false
+    }
+    task "foo" {
+      driver = "raw_exec"
+      config {
+        command = "%s"
+      }
+    }
+  }
+}
+EOT
+}`, jobID, command)
+}
+
 var testResourceJob_serviceNoDeployment = `
 resource "nomad_job" "service" {
   detach = false
@@ -2403,6 +4295,40 @@ job "foo-csi-controller" {
 }
 `
 
+var testResourceJob_multiregionNoDetach = `
+resource "nomad_job" "multiregion" {
+	detach = false
+	jobspec = <<EOT
+job "foo-multiregion-nodetach" {
+  multiregion {
+    strategy {
+      max_parallel = 1
+      on_failure   = "fail_all"
+    }
+    region "global" {
+       datacenters = ["dc1"]
+       count = 1
+    }
+  }
+  group "foo" {
+    task "foo" {
+      driver = "raw_exec"
+      config {
+        command = "/bin/sleep"
+        args    = ["3600"]
+      }
+
+      resources {
+        cpu    = 100
+        memory = 10
+      }
+    }
+  }
+}
+	EOT
+}
+`
+
 var testResourceJob_multiregion = `
 resource "nomad_job" "multiregion" {
 	jobspec = <<EOT
@@ -2431,3 +4357,189 @@ job "foo-multiregion" {
 	EOT
 }
 `
+
+func TestJobAlreadyRegistered(t *testing.T) {
+	newJob := func(count int) *api.Job {
+		job := api.NewServiceJob("foo", "foo", "global", 50)
+		job.Namespace = helper.StringToPtr("default")
+		job.AddTaskGroup(api.NewTaskGroup("web", count).
+			AddTask(api.NewTask("web", "docker").SetConfig("image", "nginx")))
+		return job
+	}
+	registered := newJob(2)
+	modifyIndex := uint64(12)
+	registered.JobModifyIndex = &modifyIndex
+
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo": func(*http.Request) interface{} {
+			return registered
+		},
+		"/v1/job/foo/evaluations": func(*http.Request) interface{} {
+			return []*api.Evaluation{
+				{ID: "eval-2", JobModifyIndex: 12},
+				{ID: "eval-1", JobModifyIndex: 10},
+			}
+		},
+	})
+	conflict := errors.New("Enforcing job modify index 10: job exists with conflicting job modify index: 12")
+
+	// The first attempt registered the job
+	resp, ok, err := jobAlreadyRegistered(client, newJob(2), 10, conflict)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, &api.JobRegisterResponse{EvalID: "eval-2", JobModifyIndex: 12}, resp)
+
+	// The job was modified by someone else
+	_, ok, err = jobAlreadyRegistered(client, newJob(3), 10, conflict)
+	require.Equal(t, conflict, err)
+	require.False(t, ok)
+
+	// The job was not modified since the index to enforce
+	_, ok, err = jobAlreadyRegistered(client, newJob(2), 12, conflict)
+	require.Equal(t, conflict, err)
+	require.False(t, ok)
+}
+
+func TestResourceJobDeregister_destroyModes(t *testing.T) {
+	testCases := []struct {
+		mode     string
+		requests []string
+	}{
+		{
+			mode:     DestroyModeStop,
+			requests: []string{"GET /v1/job/foo?namespace=default", "PUT /v1/jobs?namespace=default"},
+		},
+		{
+			mode:     DestroyModeDeregister,
+			requests: []string{"DELETE /v1/job/foo?namespace=default&purge=false"},
+		},
+		{
+			mode:     DestroyModePurge,
+			requests: []string{"DELETE /v1/job/foo?namespace=default&purge=true"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			var requests []string
+			var register api.JobRegisterRequest
+			client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+				"/v1/job/foo": func(r *http.Request) interface{} {
+					requests = append(requests, r.Method+" "+r.URL.RequestURI())
+					if r.Method == http.MethodDelete {
+						return &api.JobDeregisterResponse{EvalID: "eval-1"}
+					}
+					job := api.NewServiceJob("foo", "foo", "global", 50)
+					modifyIndex := uint64(12)
+					job.JobModifyIndex = &modifyIndex
+					return job
+				},
+				"/v1/jobs": func(r *http.Request) interface{} {
+					requests = append(requests, r.Method+" "+r.URL.RequestURI())
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&register))
+					return &api.JobRegisterResponse{EvalID: "eval-1"}
+				},
+			})
+
+			d := resourceJob().TestResourceData()
+			d.SetId("foo")
+			require.NoError(t, d.Set("deregister_on_destroy", true))
+			require.NoError(t, d.Set("destroy_mode", tc.mode))
+			require.NoError(t, resourceJobDeregister(d, ProviderConfig{client: client}))
+			require.Equal(t, tc.requests, requests)
+
+			// The stopped job stays registered, enforcing its modify index
+			if tc.mode == DestroyModeStop {
+				require.True(t, *register.Job.Stop)
+				require.True(t, register.EnforceIndex)
+				require.Equal(t, uint64(12), register.JobModifyIndex)
+			}
+		})
+	}
+}
+
+func TestResourceJobDeregister_alreadyPurged(t *testing.T) {
+	// Nomad answers 404 to a purge retried after it was applied
+	client := testNomadAPI(t, nil)
+
+	d := resourceJob().TestResourceData()
+	d.SetId("foo")
+	require.NoError(t, d.Set("deregister_on_destroy", true))
+	require.NoError(t, d.Set("destroy_mode", DestroyModePurge))
+	require.NoError(t, resourceJobDeregister(d, ProviderConfig{client: client}))
+
+	// while a job that is not found cannot be stopped
+	require.NoError(t, d.Set("destroy_mode", DestroyModeDeregister))
+	err := resourceJobDeregister(d, ProviderConfig{client: client})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "404")
+}
+
+func TestRevertJobToStableVersion(t *testing.T) {
+	var revert api.JobRevertRequest
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo/versions": func(*http.Request) interface{} {
+			version := func(v uint64, stable bool) *api.Job {
+				job := api.NewServiceJob("foo", "foo", "global", 50)
+				job.Version = &v
+				job.Stable = &stable
+				return job
+			}
+			return &api.JobVersionsResponse{
+				Versions: []*api.Job{version(3, false), version(2, true), version(1, true)},
+			}
+		},
+		"/v1/job/foo/revert": func(r *http.Request) interface{} {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&revert))
+			return &api.JobRegisterResponse{EvalID: "eval-1"}
+		},
+	})
+
+	deployment := &api.Deployment{ID: "deployment-1", JobID: "foo", Namespace: "default", JobVersion: 3}
+	version, err := revertJobToStableVersion(client, deployment, "consul", "vault")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), version)
+
+	// The revert is enforced against the version of the deployment and
+	// sends the tokens the job was registered with
+	require.Equal(t, uint64(2), revert.JobVersion)
+	require.Equal(t, uint64(3), *revert.EnforcePriorVersion)
+	require.Equal(t, "consul", revert.ConsulToken)
+	require.Equal(t, "vault", revert.VaultToken)
+}
+
+func TestRevertJobToStableVersion_jobTokens(t *testing.T) {
+	var revert api.JobRevertRequest
+	client := testNomadAPI(t, map[string]func(*http.Request) interface{}{
+		"/v1/job/foo/versions": func(*http.Request) interface{} {
+			version, stable := uint64(1), true
+			job := api.NewServiceJob("foo", "foo", "global", 50)
+			job.Version, job.Stable = &version, &stable
+			return &api.JobVersionsResponse{Versions: []*api.Job{job}}
+		},
+		"/v1/job/foo/revert": func(r *http.Request) interface{} {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&revert))
+			return &api.JobRegisterResponse{EvalID: "eval-1"}
+		},
+	})
+
+	// The tokens of the job take precedence over the ones of the provider,
+	// as for the registration
+	providerConsulToken := "provider-consul"
+	providerConfig := ProviderConfig{
+		client:      client,
+		vaultToken:  staticTokenSource("provider-vault"),
+		consulToken: &providerConsulToken,
+	}
+	d := resourceJob().TestResourceData()
+	require.NoError(t, d.Set("vault_token", "job-vault"))
+	require.NoError(t, d.Set("consul_token", "job-consul"))
+
+	vaultToken, consulToken, err := jobTokens(d, providerConfig)
+	require.NoError(t, err)
+	deployment := &api.Deployment{ID: "deployment-1", JobID: "foo", Namespace: "default", JobVersion: 2}
+	_, err = revertJobToStableVersion(client, deployment, stringValue(consulToken), stringValue(vaultToken))
+	require.NoError(t, err)
+	require.Equal(t, "job-consul", revert.ConsulToken)
+	require.Equal(t, "job-vault", revert.VaultToken)
+}